@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	ACME_TLS_PORT    = 443
+	ACME_HTTP01_PORT = 80
+)
+
+// newACMEManager returns a manager that obtains and renews the certificate
+// of host from Let's Encrypt, keeping it and the account key in cacheDir.
+// Since those are private keys, cacheDir is created if missing and must not
+// be accessible to group or others.
+func newACMEManager(host, cacheDir, email string) (*autocert.Manager, error) {
+	if cacheDir == "" {
+		return nil, fmt.Errorf("--acme-host requires --acme-cache")
+	}
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return nil, fmt.Errorf("creating ACME cache: %w", err)
+	}
+	info, err := os.Stat(cacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("ACME cache: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("ACME cache %s is not a directory", cacheDir)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return nil, fmt.Errorf("ACME cache %s is accessible to others (mode %04o): chmod 700 it", cacheDir, perm)
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(host),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      email,
+	}, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// ALERTMANAGER_RESEND is how often firing alerts are posted again;
+	// they are posted to end ALERTMANAGER_RESEND_LAPSES resends later, so
+	// an exporter that dies leaves them to resolve on their own.
+	ALERTMANAGER_RESEND        = time.Minute
+	ALERTMANAGER_RESEND_LAPSES = 4
+	ALERTMANAGER_TIMEOUT       = 10 * time.Second
+
+	ALERT_DATA_STALE     = "TigoDataStale"
+	ALERT_MODULE_OFFLINE = "TigoModuleOffline"
+)
+
+var alertmanagerPosts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tigo_alertmanager_posts_total",
+		Help: help("tigo_alertmanager_posts_total", "Number of posts of alerts to Alertmanager by result (ok or error)"),
+	},
+	[]string{"result"},
+)
+
+// amAlert is an alert as posted to the Alertmanager v2 API.
+type amAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// alertmanagerClient raises alerts in Alertmanager for sites without a
+// Prometheus to evaluate rules: TigoDataStale while the data is older than
+// STALE_TIMEOUT and TigoModuleOffline for every module that reports nothing
+// while others do. An alert is identified by its labels, the alert name plus
+// the module name, so the same condition seen again after a restart of the
+// exporter updates the alert Alertmanager already has. Alerts whose
+// condition clears are posted once more with endsAt set to resolve them.
+// Posts are sent from a separate goroutine; one still waiting when the next
+// is due is replaced by it.
+type alertmanagerClient struct {
+	endpoint string
+	labels   map[string]string
+	client   *http.Client
+	pending  chan []amAlert
+
+	firing   map[string]*amAlert
+	lastSent time.Time
+}
+
+func newAlertmanagerClient(rawURL, proxyURL string, labels map[string]string) (*alertmanagerClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid --alertmanager-url %q: want an http or https URL", rawURL)
+	}
+	client, err := newHTTPClient(ALERTMANAGER_TIMEOUT, "--alertmanager-proxy-url", proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	prometheus.MustRegister(alertmanagerPosts)
+
+	a := &alertmanagerClient{
+		endpoint: strings.TrimRight(rawURL, "/") + "/api/v2/alerts",
+		labels:   labels,
+		client:   client,
+		pending:  make(chan []amAlert, 1),
+		firing:   make(map[string]*amAlert),
+	}
+	go a.run()
+	return a, nil
+}
+
+// update is called after every refresh cycle with the conditions that hold
+// now, and posts what changed, or everything firing once it is due again.
+func (a *alertmanagerClient) update(stale bool, offline []string) {
+	now := time.Now()
+	active := make(map[string]map[string]string)
+	if stale {
+		active[ALERT_DATA_STALE] = a.alertLabels(ALERT_DATA_STALE, "")
+	}
+	for _, name := range offline {
+		active[ALERT_MODULE_OFFLINE+"/"+name] = a.alertLabels(ALERT_MODULE_OFFLINE, name)
+	}
+
+	var batch []amAlert
+	changed := false
+	for key, labels := range active {
+		if _, ok := a.firing[key]; !ok {
+			a.firing[key] = &amAlert{Labels: labels, Annotations: alertAnnotations(labels), StartsAt: now}
+			changed = true
+		}
+	}
+	for key, alert := range a.firing {
+		if _, ok := active[key]; !ok {
+			resolved := *alert
+			resolved.EndsAt = now
+			batch = append(batch, resolved)
+			delete(a.firing, key)
+			changed = true
+		}
+	}
+	if !changed && now.Sub(a.lastSent) < ALERTMANAGER_RESEND {
+		return
+	}
+
+	for _, alert := range a.firing {
+		alert.EndsAt = now.Add(ALERTMANAGER_RESEND_LAPSES * ALERTMANAGER_RESEND)
+		batch = append(batch, *alert)
+	}
+	a.lastSent = now
+	if len(batch) == 0 {
+		return
+	}
+	select {
+	case <-a.pending:
+	default:
+	}
+	a.pending <- batch
+}
+
+func (a *alertmanagerClient) alertLabels(alertname, module string) map[string]string {
+	labels := map[string]string{"alertname": alertname}
+	for k, v := range a.labels {
+		labels[k] = v
+	}
+	if module != "" {
+		labels["name"] = module
+	}
+	return labels
+}
+
+func alertAnnotations(labels map[string]string) map[string]string {
+	if labels["alertname"] == ALERT_DATA_STALE {
+		return map[string]string{"summary": fmt.Sprintf("Tigo data has not changed for over %s", STALE_TIMEOUT)}
+	}
+	return map[string]string{"summary": fmt.Sprintf("Tigo module %s reports no values while others do", labels["name"])}
+}
+
+func (a *alertmanagerClient) run() {
+	for batch := range a.pending {
+		if err := a.post(batch); err != nil {
+			log.Printf("Error posting alerts to Alertmanager: %v", err)
+			alertmanagerPosts.WithLabelValues("error").Inc()
+			continue
+		}
+		alertmanagerPosts.WithLabelValues("ok").Inc()
+	}
+}
+
+func (a *alertmanagerClient) post(batch []amAlert) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Post(a.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", redactURL(a.endpoint), resp.Status)
+	}
+	return nil
+}
+
+// offlineModules returns the modules of s that reported no value in the
+// published record while at least one other module did, so an array at rest
+// at night does not count as offline.
+func offlineModules(s *snapshot) []string {
+	var silent []string
+	reporting := false
+	for _, m := range s.Modules {
+		if len(m.Values) == 0 {
+			silent = append(silent, m.Name)
+		} else {
+			reporting = true
+		}
+	}
+	if !reporting {
+		return nil
+	}
+	sort.Strings(silent)
+	return silent
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The ambient gauges are only registered with --ambient-csv or
+// --ambient-column.
+var (
+	ambientTemp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tigo_ambient_temp_celsius",
+			Help: help("tigo_ambient_temp_celsius", "Ambient temperature at the array in celsius"),
+		},
+	)
+	moduleTempDelta = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_module_temp_delta",
+			Help: help("tigo_module_temp_delta", "Module temperature minus the ambient temperature, in degrees of --temp-unit"),
+		},
+		[]string{"name"},
+	)
+)
+
+// ambientSource is where the ambient temperature comes from: the last row
+// of a small CSV file of timestamp,celsius rows written by a separate sensor,
+// or a column of the DAQS file itself. A reading from the CSV file more than
+// STALE_TIMEOUT away from the published record is not used.
+type ambientSource struct {
+	csvPath string
+	column  string
+	warned  bool
+}
+
+// read returns the ambient temperature in celsius for record of a file with
+// headers, whose timestamp is ts.
+func (a *ambientSource) read(headers, record []string, ts float64) (float64, bool) {
+	if a.column != "" {
+		i := columnIndex(headers, a.column)
+		if i < 0 || i >= len(record) {
+			return 0, false
+		}
+		celsius, err := getFieldValue(record[i])
+		return celsius, err == nil
+	}
+
+	celsius, at, err := lastAmbientReading(a.csvPath)
+	if err != nil {
+		if !a.warned {
+			a.warned = true
+			log.Printf("Error reading ambient temperature: %v", err)
+		}
+		return 0, false
+	}
+	a.warned = false
+	if math.Abs(ts-at) > STALE_TIMEOUT.Seconds() {
+		return 0, false
+	}
+	return celsius, true
+}
+
+// lastAmbientReading returns the celsius and timestamp of the last row of
+// the ambient CSV at path that parses; a header row and garbage are skipped.
+func lastAmbientReading(path string) (float64, float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	rdr := csv.NewReader(file)
+	rdr.FieldsPerRecord = -1
+	records, _, err := readRecords(rdr, path, 1)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if len(records[i]) < 2 {
+			continue
+		}
+		ts, errTs := getFieldValue(records[i][0])
+		celsius, errTemp := getFieldValue(records[i][1])
+		if errTs == nil && errTemp == nil {
+			return celsius, toSeconds(ts), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no readings in %s", path)
+}
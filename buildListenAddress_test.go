@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestBuildListenAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		host string
+		port uint16
+		want string
+	}{
+		{"ipv4", "192.168.1.10", 9980, "192.168.1.10:9980"},
+		{"ipv6", "::1", 9980, "[::1]:9980"},
+		{"bracketed ipv6", "[fe80::1]", 8080, "[fe80::1]:8080"},
+		{"empty host", "", 9980, DEFAULT_BIND_IP + ":9980"},
+		{"hostname", "cca.local", 9980, "cca.local:9980"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildListenAddress(tt.host, tt.port); got != tt.want {
+				t.Errorf("buildListenAddress(%q, %d) = %q, want %q", tt.host, tt.port, got, tt.want)
+			}
+		})
+	}
+}
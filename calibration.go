@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// linearCalibration corrects a reading as value*Scale + Offset.
+type linearCalibration struct {
+	Scale  float64 `json:"scale"`
+	Offset float64 `json:"offset"`
+}
+
+// calibrations maps module name and field ("volts", "rssi", "power", "temp",
+// "snr", "noise") to the correction applied before the value is exported, e.g.
+//
+//	{"A1": {"rssi": {"scale": 1.05, "offset": -3}, "temp": {"scale": 1, "offset": 1.5}}}
+//
+// Temperatures are corrected in the unit selected by --temp-unit.
+type calibrations map[string]map[string]linearCalibration
+
+// calibration is loaded from --calibration-file and used by updateGauge.
+var calibration calibrations
+
+var calibrationFields = map[string]bool{
+	"volts":      true,
+	"volts_out":  true,
+	"current_in": true,
+	"rssi":       true,
+	"power":      true,
+	"temp":       true,
+	"snr":        true,
+	"noise":      true,
+}
+
+func loadCalibrations(path string) (calibrations, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c calibrations
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing calibration file %s: %w", path, err)
+	}
+	for module, fields := range c {
+		for field, cal := range fields {
+			if !calibrationFields[field] {
+				return nil, fmt.Errorf("calibration for %s: unknown field %q", module, field)
+			}
+			// A missing "scale" would otherwise zero the reading.
+			if cal.Scale == 0 {
+				return nil, fmt.Errorf("calibration for %s %s: scale must be non-zero", module, field)
+			}
+		}
+	}
+	return c, nil
+}
+
+// apply returns value corrected for field of module. Modules and fields
+// without an entry pass through unchanged.
+func (c calibrations) apply(module, field string, value float64) float64 {
+	cal, ok := c[module][field]
+	if !ok {
+		return value
+	}
+	return value*cal.Scale + cal.Offset
+}
+
+// unitScales maps a field to the multiplier that brings the source's native
+// unit to the exported one, e.g. "volts" 0.001 for firmwares that log mV.
+// Unlike calibrations they apply to every module.
+type unitScales map[string]float64
+
+// unitScale is loaded from --unit-multiplier and used by updateGauge.
+var unitScale unitScales
+
+func parseUnitScales(specs []string) (unitScales, error) {
+	scales := make(unitScales)
+	for _, spec := range specs {
+		field, value, ok := strings.Cut(spec, "=")
+		field = strings.TrimSpace(field)
+		if !ok || !calibrationFields[field] {
+			return nil, fmt.Errorf("invalid unit multiplier %q: want FIELD=FACTOR with FIELD one of volts volts_out current_in rssi power temp snr noise", spec)
+		}
+		factor, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || factor == 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+			return nil, fmt.Errorf("invalid unit multiplier %q: FACTOR must be a finite non-zero number", spec)
+		}
+		scales[field] = factor
+	}
+	return scales, nil
+}
+
+// apply returns value in the exported unit of field. Fields without a
+// multiplier pass through unchanged.
+func (s unitScales) apply(field string, value float64) float64 {
+	if factor, ok := s[field]; ok {
+		return value * factor
+	}
+	return value
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ccaMetricsHandler serves metrics at /cca/<name>/metrics for the source
+// called name, so a Prometheus tenant can be pointed at one site by path.
+// Only the single-source case is supported: the exporter reads one source
+// and has no configuration reload, so that one path, fixed at startup,
+// serves what /metrics does, and any other name is not found.
+func ccaMetricsHandler(name string, metrics http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/cca/"), "/")
+		if source != name || rest != "metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
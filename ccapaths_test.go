@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCCAMetricsHandler(t *testing.T) {
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tigo_timestamp 1\n"))
+	})
+	handler := ccaMetricsHandler("roof", metrics)
+
+	for path, want := range map[string]int{
+		"/cca/roof/metrics":   http.StatusOK,
+		"/cca/garage/metrics": http.StatusNotFound,
+		"/cca/roof/other":     http.StatusNotFound,
+		"/cca/":               http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: got status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SEQUENCE_COLUMN is the row counter the CCA starts over from when it boots.
+const SEQUENCE_COLUMN = 0
+
+var (
+	ccaSequence = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tigo_cca_sequence",
+			Help: help("tigo_cca_sequence", "Sequence number of the last row logged by the CCA"),
+		},
+	)
+	ccaRestarts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tigo_cca_restarts_total",
+			Help: help("tigo_cca_restarts_total", "Number of times the CCA row sequence went backwards, i.e. the CCA rebooted"),
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(ccaSequence)
+	prometheus.MustRegister(ccaRestarts)
+}
+
+// restartDetector counts the times the sequence column goes backwards from
+// one row to the next. Rows not newer than the previous one are ignored, so
+// reading a file again after a restart of the exporter or a rotation does
+// not count as a reboot. Files whose first column is not a number, such as
+// those that start with the DataTime column, leave both metrics untouched.
+type restartDetector struct {
+	lastTimestamp float64
+	lastSequence  float64
+	haveLast      bool
+}
+
+func (d *restartDetector) observe(record []string, moduleCount int) {
+	if SEQUENCE_COLUMN >= len(record) {
+		return
+	}
+	ts, err := recordTimestamp(record)
+	if err != nil || (d.haveLast && ts <= d.lastTimestamp) {
+		return
+	}
+	sequence, err := getFieldValue(record[SEQUENCE_COLUMN])
+	if err != nil {
+		return
+	}
+
+	if d.haveLast && sequence < d.lastSequence {
+		ccaRestarts.Inc()
+	}
+	d.lastTimestamp = ts
+	d.lastSequence = sequence
+	d.haveLast = true
+
+	ccaSequence.Set(sequence)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	DEFAULT_CHECK_URL         = "http://localhost:9980/metrics"
+	DEFAULT_CHECK_TIMEOUT_SEC = 10
+)
+
+// checkFamilies are the metric families every healthy exporter serves.
+var checkFamilies = []string{
+	"tigo_module_power",
+	"tigo_module_volts",
+	"tigo_module_rssi",
+	"tigo_module_temp",
+	"tigo_timestamp",
+	"tigo_source_up",
+}
+
+type CheckConfig struct {
+	URL        string `arg:"--url,help:metrics URL of the exporter to check: default(http://localhost:9980/metrics)"`
+	MaxAge     int    `arg:"--max-age,help:seconds tigo_timestamp may lag behind now: default(600)"`
+	MinModules int    `arg:"--min-modules,help:minimum number of modules that must report power"`
+	Timeout    int    `arg:"--timeout,help:seconds to wait for the scrape: default(10)"`
+}
+
+// runCheck implements the check subcommand: it scrapes a running exporter and
+// exits non-zero with a list of problems if it does not look healthy.
+func runCheck(args []string) {
+	var cfg CheckConfig
+	p, err := arg.NewParser(arg.Config{Program: "tigo-exporter check"}, &cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	switch err := p.Parse(args); {
+	case err == arg.ErrHelp:
+		p.WriteHelp(os.Stdout)
+		os.Exit(0)
+	case err != nil:
+		p.Fail(err.Error())
+	}
+
+	if cfg.URL == "" {
+		cfg.URL = DEFAULT_CHECK_URL
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = int(STALE_TIMEOUT.Seconds())
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DEFAULT_CHECK_TIMEOUT_SEC
+	}
+
+	families, err := scrapeFamilies(cfg.URL, time.Duration(cfg.Timeout)*time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAIL: %v\n", err)
+		os.Exit(1)
+	}
+
+	problems := checkMetrics(families, &cfg, time.Now())
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "FAIL: %s\n", cfg.URL)
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("OK: %s\n", cfg.URL)
+}
+
+func scrapeFamilies(url string, timeout time.Duration) (map[string]*dto.MetricFamily, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("scraping %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scraping %s: %s", url, resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics from %s: %w", url, err)
+	}
+	return families, nil
+}
+
+// checkMetrics returns a human-readable description of everything wrong with
+// the scraped families.
+func checkMetrics(families map[string]*dto.MetricFamily, cfg *CheckConfig, now time.Time) []string {
+	var problems []string
+	for _, name := range checkFamilies {
+		if mf, ok := families[name]; !ok || len(mf.GetMetric()) == 0 {
+			problems = append(problems, fmt.Sprintf("%s is missing", name))
+		}
+	}
+
+	if mf, ok := families["tigo_timestamp"]; ok {
+		for _, m := range mf.GetMetric() {
+			ts := m.GetGauge().GetValue()
+			if age := now.Sub(time.Unix(int64(ts), 0)); age > time.Duration(cfg.MaxAge)*time.Second {
+				problems = append(problems, fmt.Sprintf("tigo_timestamp is %s old, more than %ds", age.Round(time.Second), cfg.MaxAge))
+			}
+		}
+	}
+
+	if mf, ok := families["tigo_source_up"]; ok {
+		for _, m := range mf.GetMetric() {
+			if m.GetGauge().GetValue() != 1 {
+				problems = append(problems, "tigo_source_up is 0")
+			}
+		}
+	}
+
+	if cfg.MinModules > 0 {
+		modules := 0
+		if mf, ok := families["tigo_module_power"]; ok {
+			modules = len(mf.GetMetric())
+		}
+		if modules < cfg.MinModules {
+			problems = append(problems, fmt.Sprintf("%d modules report power, want at least %d", modules, cfg.MinModules))
+		}
+	}
+	return problems
+}
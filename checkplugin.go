@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Exit codes of a Nagios/Icinga plugin.
+const (
+	PLUGIN_OK       = 0
+	PLUGIN_WARNING  = 1
+	PLUGIN_CRITICAL = 2
+	PLUGIN_UNKNOWN  = 3
+
+	DEFAULT_CHECK_DAYLIGHT = "09:00-16:00"
+)
+
+var pluginStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// daylightWindow is a time of day, in --timezone, such as the one during
+// which the array is expected to produce at least the --check-*-power
+// thresholds.
+type daylightWindow struct {
+	from, to time.Duration
+}
+
+// parseDaylightWindow parses the HH:MM-HH:MM spec given to flag.
+func parseDaylightWindow(flag, spec string) (daylightWindow, error) {
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return daylightWindow{}, fmt.Errorf("invalid %s %q: want HH:MM-HH:MM", flag, spec)
+	}
+	var w daylightWindow
+	for _, part := range []struct {
+		text string
+		dst  *time.Duration
+	}{{from, &w.from}, {to, &w.to}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part.text))
+		if err != nil {
+			return daylightWindow{}, fmt.Errorf("invalid %s %q: want HH:MM-HH:MM", flag, spec)
+		}
+		*part.dst = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if w.to <= w.from {
+		return daylightWindow{}, fmt.Errorf("invalid %s %q: end must be after start", flag, spec)
+	}
+	return w, nil
+}
+
+func (w daylightWindow) contains(t time.Time) bool {
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	return sinceMidnight >= w.from && sinceMidnight < w.to
+}
+
+// pluginCheck is the outcome of a --check run.
+type pluginCheck struct {
+	state    int
+	problems []string
+}
+
+// raise records a problem and moves the state up to at least state.
+func (c *pluginCheck) raise(state int, format string, args ...interface{}) {
+	if state > c.state {
+		c.state = state
+	}
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+// runPluginCheck implements --check: it reads the newest file of the source
+// once, prints a Nagios/Icinga plugin line with performance data and returns
+// the plugin exit code. Thresholds left at 0 are not checked.
+func runPluginCheck(cfg *Config, source dataSource, opts csvOptions, location *time.Location, monitoring *monitoringOnly) int {
+	window, err := parseDaylightWindow("--check-daylight", cfg.CheckDaylight)
+	if err != nil {
+		fmt.Printf("TIGO UNKNOWN - %v\n", err)
+		return PLUGIN_UNKNOWN
+	}
+
+	newest, err := source.Newest()
+	if err != nil {
+		fmt.Printf("TIGO UNKNOWN - %v\n", err)
+		return PLUGIN_UNKNOWN
+	}
+	file, err := source.Open(newest.Path, 0)
+	if err != nil {
+		fmt.Printf("TIGO UNKNOWN - %v\n", err)
+		return PLUGIN_UNKNOWN
+	}
+	daqs, err := parseDAQS(file, newest.Path, opts)
+	file.Close()
+	if err != nil {
+		fmt.Printf("TIGO UNKNOWN - parsing %s: %v\n", newest.Path, err)
+		return PLUGIN_UNKNOWN
+	}
+	if len(daqs.Records) == 0 {
+		fmt.Printf("TIGO UNKNOWN - no records in %s\n", newest.Path)
+		return PLUGIN_UNKNOWN
+	}
+	if daqs.Layout != nil {
+		moduleNames = daqs.Layout.names
+	}
+
+	record := daqs.Records[len(daqs.Records)-1]
+	ts, err := recordTimestamp(record)
+	if err != nil {
+		fmt.Printf("TIGO UNKNOWN - last record of %s: %v\n", newest.Path, err)
+		return PLUGIN_UNKNOWN
+	}
+
+	modules, reporting := 0, 0
+	power := 0.0
+	for i := 1; i <= daqs.ModuleCount; i++ {
+		if monitoring.is(moduleName(i)) {
+			continue
+		}
+		modules++
+		if pin, err := moduleField(record, i, PIN_OFFSET); err == nil {
+			reporting++
+			power += pin
+		}
+	}
+	recorded := time.Unix(int64(ts), 0)
+	age := time.Since(recorded).Seconds()
+
+	var check pluginCheck
+	if cfg.CheckCritModules > 0 && reporting < cfg.CheckCritModules {
+		check.raise(PLUGIN_CRITICAL, "%d modules reporting < %d", reporting, cfg.CheckCritModules)
+	} else if cfg.CheckWarnModules > 0 && reporting < cfg.CheckWarnModules {
+		check.raise(PLUGIN_WARNING, "%d modules reporting < %d", reporting, cfg.CheckWarnModules)
+	}
+	if cfg.CheckCritAge > 0 && age > float64(cfg.CheckCritAge) {
+		check.raise(PLUGIN_CRITICAL, "data %.0fs old > %ds", age, cfg.CheckCritAge)
+	} else if cfg.CheckWarnAge > 0 && age > float64(cfg.CheckWarnAge) {
+		check.raise(PLUGIN_WARNING, "data %.0fs old > %ds", age, cfg.CheckWarnAge)
+	}
+	if window.contains(recorded.In(location)) {
+		if cfg.CheckCritPower > 0 && power < cfg.CheckCritPower {
+			check.raise(PLUGIN_CRITICAL, "%.0fW < %.0fW in daylight", power, cfg.CheckCritPower)
+		} else if cfg.CheckWarnPower > 0 && power < cfg.CheckWarnPower {
+			check.raise(PLUGIN_WARNING, "%.0fW < %.0fW in daylight", power, cfg.CheckWarnPower)
+		}
+	}
+
+	summary := fmt.Sprintf("%d/%d modules reporting, %.1fkW", reporting, modules, power/1000)
+	if len(check.problems) > 0 {
+		summary += ": " + strings.Join(check.problems, ", ")
+	}
+	fmt.Printf("TIGO %s - %s | array_power=%.0fW;%s;%s;0 modules_reporting=%d;%s;%s;0;%d data_age=%.0fs;%s;%s;0\n",
+		pluginStates[check.state], summary,
+		power, minThreshold(cfg.CheckWarnPower), minThreshold(cfg.CheckCritPower),
+		reporting, minThreshold(float64(cfg.CheckWarnModules)), minThreshold(float64(cfg.CheckCritModules)), modules,
+		age, maxThreshold(float64(cfg.CheckWarnAge)), maxThreshold(float64(cfg.CheckCritAge)))
+	return check.state
+}
+
+// minThreshold formats a lower bound as a performance data range, empty if
+// unset.
+func minThreshold(value float64) string {
+	if value <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%g:", value)
+}
+
+// maxThreshold formats an upper bound as a performance data range, empty if
+// unset.
+func maxThreshold(value float64) string {
+	if value <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%g", value)
+}
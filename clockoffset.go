@@ -0,0 +1,45 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ccaClockOffset = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "tigo_cca_clock_offset_seconds",
+		Help: help("tigo_cca_clock_offset_seconds", "Wall-clock time of the exporter minus the timestamp of the newest row when it was first read"),
+	},
+)
+
+func init() {
+	prometheus.MustRegister(ccaClockOffset)
+}
+
+// clockOffset measures how far the CCA clock is behind the exporter's from
+// rows as they are first read, so the offset includes the time the file took
+// to be written and read. Rows not newer than the newest one seen are
+// ignored, so rereading a file does not count its old rows as a lagging
+// clock.
+type clockOffset struct {
+	now     func() time.Time
+	newest  float64
+	haveAny bool
+}
+
+func newClockOffset() *clockOffset {
+	return &clockOffset{now: time.Now}
+}
+
+func (c *clockOffset) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil || (c.haveAny && ts <= c.newest) {
+		return
+	}
+	c.newest = ts
+	c.haveAny = true
+
+	now := float64(c.now().UnixNano()) / float64(time.Second)
+	ccaClockOffset.Set(now - ts)
+}
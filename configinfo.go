@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// registerConfigInfo registers tigo_exporter_config_info, always 1, with
+// the effective configuration as labels, so that the setups of two sites
+// can be compared from Prometheus.
+func registerConfigInfo(cfg *Config) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tigo_exporter_config_info",
+		Help: help("tigo_exporter_config_info", "Effective configuration of the exporter, always 1"),
+		ConstLabels: prometheus.Labels{
+			"source":           configSource(cfg.TigoDAQSDataDir),
+			"source_type":      configSourceType(cfg.TigoDAQSDataDir),
+			"refresh_interval": strconv.Itoa(cfg.RefreshInterval),
+			"stale_timeout":    strconv.Itoa(int(STALE_TIMEOUT.Seconds())),
+			"expected_modules": strconv.Itoa(cfg.ExpectedModules),
+			"lost_energy":      strconv.FormatBool(cfg.RatedModulePower > 0 || len(cfg.ModuleWp) > 0),
+			"strings":          strconv.FormatBool(len(cfg.Strings) > 0),
+			"calibration":      strconv.FormatBool(cfg.CalibrationFile != ""),
+			"on_demand":        strconv.FormatBool(cfg.OnDemand),
+			"spool_mode":       strconv.FormatBool(cfg.SpoolMode),
+			"temp_unit":        cfg.TempUnit,
+		},
+	})
+	gauge.Set(1)
+	prometheus.MustRegister(gauge)
+}
+
+// configSource is location without any credentials in it.
+func configSource(location string) string {
+	if !strings.Contains(location, "://") {
+		return location
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	return u.String()
+}
+
+func configSourceType(location string) string {
+	switch {
+	case strings.HasPrefix(location, "ftp://"):
+		return "ftp"
+	case strings.HasPrefix(location, "smb://"):
+		return "smb"
+	case isSQLiteLocation(location):
+		return "sqlite"
+	}
+	return "directory"
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var corruptRows = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "tigo_corrupt_rows_total",
+		Help: help("tigo_corrupt_rows_total", "Number of reads that stopped at a CSV row that does not parse"),
+	},
+)
+
+func init() {
+	prometheus.MustRegister(corruptRows)
+}
+
+// readRecords reads the records of rdr up to the first one that does not
+// parse. A file caught being written can end in a torn or garbled row, and
+// the rows before it are still good, so they are returned and the rest is
+// dropped rather than failing the whole read. The error is only returned
+// when not even the first record parses. file and firstRow, the number of
+// the first record among the data rows of file, locate the bad row in the
+// error log. stoppedAt is the input offset just past the bad row, where a
+// later read can resume to get the rows after it, or 0 if all of the input
+// parsed.
+func readRecords(rdr *csv.Reader, file string, firstRow int) (records [][]string, stoppedAt int64, err error) {
+	records, consumed, err := readRecordsAt(rdr, file, firstRow)
+	if err != nil {
+		if len(records) == 0 {
+			return nil, 0, err
+		}
+		log.Printf("Keeping %d CSV records of %s before one that does not parse: %v", len(records), file, err)
+		return records, consumed, nil
+	}
+	return records, 0, nil
+}
+
+// readRecordsAt is readRecords returning the error of the bad row along
+// with the records before it, and the input offset just past the bad row:
+// where a later read should resume so that the rows after it are not lost.
+func readRecordsAt(rdr *csv.Reader, file string, firstRow int) ([][]string, int64, error) {
+	var records [][]string
+	for {
+		record, err := rdr.Read()
+		if err == io.EOF {
+			return records, rdr.InputOffset(), nil
+		}
+		if err != nil {
+			corruptRows.Inc()
+			noteError(STAGE_PARSE, file, firstRow+len(records), "", err)
+			return records, rdr.InputOffset(), err
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseDAQSKeepsRowsBeforeCorruptTail(t *testing.T) {
+	path := filepath.Join("testdata", "corrupt_tail.csv")
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	since := time.Now()
+	daqs, err := parseDAQS(file, path, csvOptions{})
+	if err != nil {
+		t.Fatalf("parseDAQS: %v", err)
+	}
+	if len(daqs.Records) != 3 {
+		t.Fatalf("got %d records, want the 3 before the corrupt row", len(daqs.Records))
+	}
+	if got := daqs.Records[2][TIMESTAMP_COLUMN]; got != "1717243202" {
+		t.Errorf("last record has timestamp %s, want 1717243202", got)
+	}
+	if daqs.StoppedAt == 0 {
+		t.Error("StoppedAt is 0 for a read that stopped at a corrupt row")
+	}
+
+	logged := recentErrors.newest(since)
+	if len(logged) == 0 {
+		t.Fatal("corrupt row not in the error log")
+	}
+	e := logged[len(logged)-1]
+	if e.Stage != STAGE_PARSE || e.File != path || e.Row != 4 {
+		t.Errorf("logged %s error at %s row %d, want %s at %s row 4", e.Stage, e.File, e.Row, STAGE_PARSE, path)
+	}
+}
+
+func TestTailReadsRowsAfterCorruptRow(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "corrupt_tail.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "daqs.csv")
+	if err := os.WriteFile(path, fixture, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := &localSource{dataDir: filepath.Dir(path)}
+	tail := &csvTail{}
+	read := func() [][]string {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		daqs, err := tail.read(context.Background(), src, path, info.Size(), false)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return daqs.Records
+	}
+
+	if records := read(); len(records) != 3 {
+		t.Fatalf("first read got %d records, want 3", len(records))
+	}
+
+	// A good row appended after the corrupt one, then another corrupt row
+	// followed by a good one in the same batch.
+	body := fixture[bytes.IndexByte(fixture, '\n')+1:]
+	rows := bytes.SplitAfter(body, []byte("\n"))
+	good := bytes.Replace(rows[0], []byte("1717243200"), []byte("1717243204"), 1)
+	later := bytes.Replace(rows[0], []byte("1717243200"), []byte("1717243205"), 1)
+	appendFile(t, path, good, rows[3], later)
+
+	records := read()
+	if len(records) != 2 {
+		t.Fatalf("second read got %d records, want the 2 good rows around the corrupt one", len(records))
+	}
+	for i, want := range []string{"1717243204", "1717243205"} {
+		if got := records[i][TIMESTAMP_COLUMN]; got != want {
+			t.Errorf("record %d has timestamp %s, want %s", i, got, want)
+		}
+	}
+	if records := read(); len(records) != 0 {
+		t.Errorf("third read got %d records, want none", len(records))
+	}
+}
+
+func appendFile(t *testing.T, path string, chunks ...[]byte) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for _, chunk := range chunks {
+		if _, err := file.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var dataDirFreeDesc = prometheus.NewDesc(
+	"tigo_data_dir_free_bytes", help("tigo_data_dir_free_bytes", "Free space on the filesystem of the data directory in bytes"), nil, nil)
+
+// dataDirFreeCollector exports the space left for the CCA to write its data
+// to, read at scrape time. Where statfs is unavailable the metric is left
+// out, with a warning the first time.
+type dataDirFreeCollector struct {
+	path   string
+	warned sync.Once
+}
+
+func (c *dataDirFreeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dataDirFreeDesc
+}
+
+func (c *dataDirFreeCollector) Collect(ch chan<- prometheus.Metric) {
+	free, err := diskFree(c.path)
+	if err != nil {
+		c.warned.Do(func() {
+			log.Printf("Free space of %s unavailable: %v", c.path, err)
+		})
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(dataDirFreeDesc, prometheus.GaugeValue, float64(free))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const DEFAULT_GAP_THRESHOLD_SEC = 300
+
+var (
+	dataGapSeconds = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tigo_data_gap_seconds",
+			Help: help("tigo_data_gap_seconds", "Length of the most recent gap between consecutive rows longer than --gap-threshold"),
+		},
+	)
+	dataGaps = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tigo_data_gaps_total",
+			Help: help("tigo_data_gaps_total", "Number of gaps between consecutive rows longer than --gap-threshold"),
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(dataGapSeconds)
+	prometheus.MustRegister(dataGaps)
+}
+
+// gapDetector notices stretches of time the CCA logged nothing for, from
+// the timestamps of consecutive rows. Rows come in file order across files,
+// so the first row of a new file is compared with the last of the one
+// before, which catches the hour lost around a midnight rotation as well as
+// gaps within a file. Rows not newer than the previous one are ignored.
+type gapDetector struct {
+	threshold     float64
+	lastTimestamp float64
+	haveLast      bool
+}
+
+func (d *gapDetector) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil || (d.haveLast && ts <= d.lastTimestamp) {
+		return
+	}
+	if d.haveLast && ts-d.lastTimestamp > d.threshold {
+		gap := ts - d.lastTimestamp
+		log.Printf("No data for %.0f s before timestamp %.0f", gap, ts)
+		dataGapSeconds.Set(gap)
+		dataGaps.Inc()
+	}
+	d.lastTimestamp = ts
+	d.haveLast = true
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// derivedMetric is a gauge computed from the parsed columns by an arithmetic
+// expression, defined with --derived-metric LEVEL:NAME=EXPR. Module level
+// expressions see the columns of one module by their DAQS header suffix in
+// lower case (vin, iin, temp, pwm, rssi, vout, pin, ...) and are exported
+// per module; array level ones see sum_<column>, avg_<column>,
+// min_<column> and max_<column> over the modules reporting the column, and
+// modules, the number of modules.
+type derivedMetric struct {
+	name   string
+	expr   derivedExpr
+	module *prometheus.GaugeVec
+	array  prometheus.Gauge
+}
+
+func parseDerivedMetrics(specs []string) ([]*derivedMetric, error) {
+	var metrics []*derivedMetric
+	for _, spec := range specs {
+		level, rest, ok := strings.Cut(spec, ":")
+		name, text, ok2 := strings.Cut(rest, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !ok2 || !labelNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid derived metric %q: want LEVEL:NAME=EXPR", spec)
+		}
+		expr, err := parseDerivedExpr(text)
+		if err != nil {
+			return nil, fmt.Errorf("derived metric %s: %w", name, err)
+		}
+		for _, v := range exprVariables(expr) {
+			if !derivedVariable(level, v) {
+				return nil, fmt.Errorf("derived metric %s: unknown %s variable %q", name, level, v)
+			}
+		}
+
+		m := &derivedMetric{name: name, expr: expr}
+		helpText := help(name, "Derived as "+strings.TrimSpace(text))
+		switch level {
+		case "module":
+			m.module = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: helpText}, []string{"name"})
+			err = prometheus.Register(m.module)
+		case "array":
+			m.array = prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: helpText})
+			err = prometheus.Register(m.array)
+		default:
+			return nil, fmt.Errorf("derived metric %s: level must be module or array", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("derived metric %s: %w", name, err)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// derivedVariable reports whether name can appear in expressions of level.
+func derivedVariable(level, name string) bool {
+	if level == "array" {
+		if name == "modules" {
+			return true
+		}
+		for _, prefix := range []string{"sum_", "avg_", "min_", "max_"} {
+			if column, ok := strings.CutPrefix(name, prefix); ok {
+				return derivedVariable("module", column)
+			}
+		}
+		return false
+	}
+	for _, column := range daqsColumnNames {
+		if name == strings.ToLower(column) {
+			return true
+		}
+	}
+	return false
+}
+
+// exprVariables returns the variable names e refers to.
+func exprVariables(e derivedExpr) []string {
+	switch e := e.(type) {
+	case variableExpr:
+		return []string{string(e)}
+	case negateExpr:
+		return exprVariables(e.operand)
+	case binaryExpr:
+		return append(exprVariables(e.left), exprVariables(e.right)...)
+	}
+	return nil
+}
+
+// moduleVariables returns the columns of a module in record that parse as
+// numbers.
+func moduleVariables(record []string, moduleIndex int) map[string]float64 {
+	vars := make(map[string]float64, MODULE_COLUMNS)
+	for offset, column := range daqsColumnNames {
+		if v, err := moduleField(record, moduleIndex, offset); err == nil {
+			vars[strings.ToLower(column)] = v
+		}
+	}
+	return vars
+}
+
+// updateDerivedMetrics evaluates metrics against record. A module or the
+// array whose expression refers to a missing column, or does not evaluate to
+// a finite number, gets no sample.
+func updateDerivedMetrics(metrics []*derivedMetric, record []string, moduleCount int, filter *moduleFilter) {
+	if len(metrics) == 0 {
+		return
+	}
+
+	perModule := make([]map[string]float64, moduleCount)
+	array := map[string]float64{"modules": float64(moduleCount)}
+	counts := make(map[string]float64)
+	for i := range perModule {
+		perModule[i] = moduleVariables(record, i+1)
+		for column, v := range perModule[i] {
+			counts[column]++
+			array["sum_"+column] += v
+			if lo, ok := array["min_"+column]; !ok || v < lo {
+				array["min_"+column] = v
+			}
+			if hi, ok := array["max_"+column]; !ok || v > hi {
+				array["max_"+column] = v
+			}
+		}
+	}
+	for column, n := range counts {
+		array["avg_"+column] = array["sum_"+column] / n
+	}
+
+	for _, m := range metrics {
+		if m.array != nil {
+			if v, ok := m.expr.eval(array); ok {
+				m.array.Set(v)
+			}
+			continue
+		}
+		for i, vars := range perModule {
+			name := moduleName(i + 1)
+			if !filter.allows(name) {
+				continue
+			}
+			if v, ok := m.expr.eval(vars); ok {
+				m.module.WithLabelValues(name).Set(v)
+			} else {
+				m.module.DeleteLabelValues(name)
+			}
+		}
+	}
+}
+
+// resetDerivedMetrics drops the per-module series once the data is stale.
+func resetDerivedMetrics(metrics []*derivedMetric) {
+	for _, m := range metrics {
+		if m.module != nil {
+			m.module.Reset()
+		}
+	}
+}
+
+// derivedExpr is a parsed arithmetic expression.
+type derivedExpr interface {
+	// eval returns the value of the expression and false if it refers to a
+	// variable not in vars or is not a finite number.
+	eval(vars map[string]float64) (float64, bool)
+}
+
+type numberExpr float64
+
+func (e numberExpr) eval(map[string]float64) (float64, bool) {
+	return float64(e), true
+}
+
+type variableExpr string
+
+func (e variableExpr) eval(vars map[string]float64) (float64, bool) {
+	v, ok := vars[string(e)]
+	return v, ok
+}
+
+type negateExpr struct{ operand derivedExpr }
+
+func (e negateExpr) eval(vars map[string]float64) (float64, bool) {
+	v, ok := e.operand.eval(vars)
+	return -v, ok
+}
+
+type binaryExpr struct {
+	op          byte
+	left, right derivedExpr
+}
+
+func (e binaryExpr) eval(vars map[string]float64) (float64, bool) {
+	l, ok := e.left.eval(vars)
+	if !ok {
+		return 0, false
+	}
+	r, ok := e.right.eval(vars)
+	if !ok {
+		return 0, false
+	}
+	var v float64
+	switch e.op {
+	case '+':
+		v = l + r
+	case '-':
+		v = l - r
+	case '*':
+		v = l * r
+	case '/':
+		v = l / r
+	}
+	return v, !math.IsInf(v, 0) && !math.IsNaN(v)
+}
+
+// exprParser is a recursive descent parser for + - * / with the usual
+// precedence, unary minus, parentheses, numbers and variable names.
+type exprParser struct {
+	text string
+	pos  int
+}
+
+func parseDerivedExpr(text string) (derivedExpr, error) {
+	p := &exprParser{text: text}
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.text) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.text[p.pos:], p.pos)
+	}
+	return e, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.text) && p.text[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) sum() (derivedExpr, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) || (p.text[p.pos] != '+' && p.text[p.pos] != '-') {
+			return left, nil
+		}
+		op := p.text[p.pos]
+		p.pos++
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) product() (derivedExpr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) || (p.text[p.pos] != '*' && p.text[p.pos] != '/') {
+			return left, nil
+		}
+		op := p.text[p.pos]
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) unary() (derivedExpr, error) {
+	p.skipSpace()
+	if p.pos < len(p.text) && p.text[p.pos] == '-' {
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return negateExpr{operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (derivedExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.text) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	start := p.pos
+	switch c := rune(p.text[p.pos]); {
+	case c == '(':
+		p.pos++
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.skipSpace(); p.pos >= len(p.text) || p.text[p.pos] != ')' {
+			return nil, fmt.Errorf("missing ) for ( at offset %d", start)
+		}
+		p.pos++
+		return e, nil
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.text) && (unicode.IsDigit(rune(p.text[p.pos])) || p.text[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.text[start:p.pos])
+		}
+		return numberExpr(v), nil
+	case unicode.IsLetter(c) || c == '_':
+		for p.pos < len(p.text) && (unicode.IsLetter(rune(p.text[p.pos])) || unicode.IsDigit(rune(p.text[p.pos])) || p.text[p.pos] == '_') {
+			p.pos++
+		}
+		return variableExpr(strings.ToLower(p.text[start:p.pos])), nil
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", p.text[p.pos:], p.pos)
+}
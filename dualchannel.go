@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// channelLayout rearranges the columns of a DAQS file with dual-input units
+// (TS4-A-2F) into the usual layout of one MODULE_COLUMNS block per module,
+// so the rest of the exporter sees each input as a module of its own. The
+// second input of such a unit is logged in extra columns named after the
+// unit's first-input ones ("LMU_Garage_3_Vin2", "LMU_Garage_3_Pin2"); it gets
+// a block that repeats the unit's shared columns (temperature, RSSI, ...)
+// with those in place of Vin and Pin. The inputs are named "A5.1" and "A5.2".
+type channelLayout struct {
+	// columns holds the source column of every output column, or -1.
+	columns []int
+	// names holds the module name of every output block.
+	names []string
+	// sourceWidth is the number of fields in a source record.
+	sourceWidth int
+}
+
+// detectChannels returns the layout for a file with headers, or nil if it
+// has no dual-input units. width is the number of fields per record.
+func detectChannels(headers []string, width int) *channelLayout {
+	byName := make(map[string]int, len(headers))
+	for i, h := range headers {
+		byName[strings.ToLower(h)] = i
+	}
+
+	moduleCount := (len(headers) - LEADING_COLUMNS) / MODULE_COLUMNS
+	layout := &channelLayout{sourceWidth: width}
+	for c := 0; c < LEADING_COLUMNS && c < len(headers); c++ {
+		layout.columns = append(layout.columns, c)
+	}
+
+	dual := false
+	for moduleIndex := 1; moduleIndex <= moduleCount; moduleIndex++ {
+		start := LEADING_COLUMNS + (moduleIndex-1)*MODULE_COLUMNS
+		block := make([]int, MODULE_COLUMNS)
+		for offset := range block {
+			block[offset] = start + offset
+		}
+
+		vin2, pin2 := -1, -1
+		if vin := strings.ToLower(headers[start+VIN_OFFSET]); strings.HasSuffix(vin, "vin") {
+			prefix := strings.TrimSuffix(vin, "vin")
+			if i, ok := byName[prefix+"vin2"]; ok {
+				vin2 = i
+			}
+			if i, ok := byName[prefix+"pin2"]; ok {
+				pin2 = i
+			}
+		}
+
+		if vin2 < 0 || pin2 < 0 {
+			layout.columns = append(layout.columns, block...)
+			layout.names = append(layout.names, fmt.Sprintf("A%d", moduleIndex))
+			continue
+		}
+
+		dual = true
+		second := append([]int(nil), block...)
+		second[VIN_OFFSET] = vin2
+		second[PIN_OFFSET] = pin2
+		layout.columns = append(layout.columns, block...)
+		layout.columns = append(layout.columns, second...)
+		layout.names = append(layout.names, fmt.Sprintf("A%d.1", moduleIndex), fmt.Sprintf("A%d.2", moduleIndex))
+	}
+	if !dual {
+		return nil
+	}
+
+	// Whatever follows the module blocks, SNR columns for instance, is
+	// kept as is after them.
+	for c := LEADING_COLUMNS + moduleCount*MODULE_COLUMNS; c < len(headers); c++ {
+		layout.columns = append(layout.columns, c)
+	}
+	return layout
+}
+
+// apply returns record rearranged into the layout. Columns without a source
+// column, -1, are left empty.
+func (l *channelLayout) apply(record []string) []string {
+	out := make([]string, len(l.columns))
+	for i, c := range l.columns {
+		if c >= 0 && c < len(record) {
+			out[i] = record[c]
+		}
+	}
+	return out
+}
+
+// applyAll rearranges records in place.
+func (l *channelLayout) applyAll(records [][]string) {
+	for i := range records {
+		records[i] = l.apply(records[i])
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DEFAULT_EMONCMS_NODE      = "tigo"
+	EMONCMS_MIN_INTERVAL      = 10 * time.Second
+	EMONCMS_TIMEOUT           = 10 * time.Second
+	EMONCMS_MAX_ATTEMPTS      = 3
+	EMONCMS_RETRY_BACKOFF_MAX = 30 * time.Second
+)
+
+var emoncmsFailedPosts = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "tigo_emoncms_failed_posts_total",
+		Help: help("tigo_emoncms_failed_posts_total", "Number of emoncms uploads rejected by the server or abandoned after retries"),
+	},
+)
+
+func init() {
+	prometheus.MustRegister(emoncmsFailedPosts)
+}
+
+// emoncmsSample is what gets uploaded for one published record.
+type emoncmsSample struct {
+	timestamp float64
+	inputs    map[string]float64
+}
+
+// emoncmsPoster uploads the published per-module powers and the array total
+// to the emoncms input/bulk API. Uploads happen on a separate goroutine no
+// more often than EMONCMS_MIN_INTERVAL; if records are published faster than
+// that, only the newest one waiting is sent.
+type emoncmsPoster struct {
+	endpoint string
+	apiKey   string
+	node     string
+	client   *http.Client
+	pending  chan emoncmsSample
+}
+
+func newEmoncmsPoster(cfg *Config) (*emoncmsPoster, error) {
+	if cfg.EmoncmsAPIKey == "" {
+		return nil, fmt.Errorf("--emoncms-apikey is required with --emoncms-url")
+	}
+	if _, err := url.Parse(cfg.EmoncmsURL); err != nil {
+		return nil, fmt.Errorf("invalid --emoncms-url: %w", err)
+	}
+	if cfg.EmoncmsNode == "" {
+		cfg.EmoncmsNode = DEFAULT_EMONCMS_NODE
+	}
+	client, err := newHTTPClient(EMONCMS_TIMEOUT, "--emoncms-proxy-url", cfg.EmoncmsProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &emoncmsPoster{
+		endpoint: strings.TrimRight(cfg.EmoncmsURL, "/") + "/input/bulk",
+		apiKey:   cfg.EmoncmsAPIKey,
+		node:     cfg.EmoncmsNode,
+		client:   client,
+		pending:  make(chan emoncmsSample, 1),
+	}
+	go e.run()
+	return e, nil
+}
+
+// post queues the module powers of s for upload, replacing any sample that
+// has not been sent yet.
+func (e *emoncmsPoster) post(s *snapshot) {
+	sample := emoncmsSample{timestamp: s.Timestamp, inputs: make(map[string]float64)}
+	for _, m := range s.Modules {
+		if power, ok := m.Values["power"]; ok {
+			sample.inputs[m.Name] = power
+		}
+	}
+	sample.inputs["total"] = s.systemPower()
+
+	for {
+		select {
+		case e.pending <- sample:
+			return
+		default:
+		}
+		select {
+		case <-e.pending:
+		default:
+		}
+	}
+}
+
+func (e *emoncmsPoster) run() {
+	var lastPost time.Time
+	for sample := range e.pending {
+		if wait := EMONCMS_MIN_INTERVAL - time.Since(lastPost); wait > 0 {
+			time.Sleep(wait)
+			// Something newer may have been published in the meantime.
+			select {
+			case sample = <-e.pending:
+			default:
+			}
+		}
+		lastPost = time.Now()
+
+		backoff := time.Second
+		for attempt := 1; ; attempt++ {
+			retry, err := e.upload(sample)
+			if err == nil {
+				break
+			}
+			if !retry || attempt == EMONCMS_MAX_ATTEMPTS {
+				log.Printf("Error posting to emoncms: %v", err)
+				emoncmsFailedPosts.Inc()
+				break
+			}
+			time.Sleep(backoff)
+			backoff = min(backoff*2, EMONCMS_RETRY_BACKOFF_MAX)
+		}
+	}
+}
+
+// upload sends one sample as a bulk payload of a single frame, timed by the
+// row's own timestamp. It reports whether a failure is worth retrying:
+// network errors, rate limiting and server errors are, anything else the
+// server rejects is not.
+func (e *emoncmsPoster) upload(sample emoncmsSample) (bool, error) {
+	data, err := json.Marshal([][]any{{0, e.node, sample.inputs}})
+	if err != nil {
+		return false, err
+	}
+	form := url.Values{
+		"data":   {string(data)},
+		"time":   {strconv.FormatInt(int64(sample.timestamp), 10)},
+		"apikey": {e.apiKey},
+	}
+
+	resp, err := e.client.PostForm(e.endpoint, form)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("emoncms returned %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("emoncms returned %s", resp.Status)
+	case !bytes.Equal(bytes.TrimSpace(body), []byte("ok")):
+		return false, fmt.Errorf("emoncms rejected upload: %s", bytes.TrimSpace(body))
+	}
+	return false, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RECENT_ERRORS is how many errors /api/v1/errors remembers.
+const RECENT_ERRORS = 200
+
+// Stages of the refresh cycle an error is recorded for.
+const (
+	STAGE_WALK  = "walk"
+	STAGE_READ  = "read"
+	STAGE_PARSE = "parse"
+)
+
+// recordedError is one entry of /api/v1/errors. Row is the 1-based number
+// of the record among the data rows of File, 0 when not known, and Value the
+// offending cell.
+type recordedError struct {
+	Time    time.Time `json:"time"`
+	Stage   string    `json:"stage"`
+	File    string    `json:"file,omitempty"`
+	Row     int       `json:"row,omitempty"`
+	Value   string    `json:"value,omitempty"`
+	Message string    `json:"message"`
+}
+
+// errorRing keeps the most recent errors, overwriting the oldest once full.
+type errorRing struct {
+	mu      sync.Mutex
+	entries []recordedError
+	next    int
+}
+
+// recentErrors is filled whether or not --debug-endpoints serves it.
+var recentErrors = &errorRing{entries: make([]recordedError, 0, RECENT_ERRORS)}
+
+// noteError records an error of stage; value is the offending cell, if any.
+func noteError(stage, file string, row int, value string, err error) {
+	recentErrors.add(recordedError{
+		Time:    time.Now(),
+		Stage:   stage,
+		File:    file,
+		Row:     row,
+		Value:   value,
+		Message: err.Error(),
+	})
+}
+
+// noteBadCell records a cell of the published record that did not parse,
+// under the header of its column. Empty cells are left out: modules report
+// none at night, so they are routine rather than a layout problem.
+func noteBadCell(file string, row int, headers []string, index int, value string, err error) {
+	if value == "" {
+		return
+	}
+	column := fmt.Sprintf("column %d", index+1)
+	if index < len(headers) {
+		column = headers[index]
+	}
+	noteError(STAGE_PARSE, file, row, value, fmt.Errorf("%s: %w", column, err))
+}
+
+func (r *errorRing) add(e recordedError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+}
+
+// newest returns the errors recorded after since, newest first.
+func (r *errorRing) newest(since time.Time) []recordedError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]recordedError, 0, len(r.entries))
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		e := r.entries[(r.next+i)%len(r.entries)]
+		if !e.Time.After(since) {
+			// Entries are in time order, so the rest are older still.
+			break
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// errorsHandler serves the recent errors as JSON. ?since= takes an RFC 3339
+// time or unix seconds and leaves out the errors up to it.
+func errorsHandler(ring *errorRing) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			t, err := parseSince(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			since = t
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ring.newest(since))
+	})
+}
+
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(secs*float64(time.Second))), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q: want an RFC 3339 time or unix seconds", s)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// modulePowerSamples is only registered with --exemplars. OpenMetrics allows
+// exemplars on counters and histograms but not on gauges, so the exemplar
+// pointing at the source row of every power reading rides on this counter of
+// readings instead of on tigo_module_power itself.
+var modulePowerSamples = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tigo_module_power_samples_total",
+		Help: help("tigo_module_power_samples_total", "Number of power readings published for a module, with the file and row of the last one as exemplar"),
+	},
+	[]string{"name"},
+)
+
+// EXEMPLAR_FILE_RUNES leaves room for the row number and the label names
+// within prometheus.ExemplarMaxRunes.
+const EXEMPLAR_FILE_RUNES = 100
+
+// addPowerExemplar counts a power reading of module taken from row of
+// csvFile. row is 0 when the row number is not known, and then the reading
+// is counted without an exemplar.
+func addPowerExemplar(module, csvFile string, row int) {
+	counter := modulePowerSamples.WithLabelValues(module)
+	if row <= 0 {
+		counter.Inc()
+		return
+	}
+
+	file := []rune(filepath.Base(csvFile))
+	if len(file) > EXEMPLAR_FILE_RUNES {
+		file = file[len(file)-EXEMPLAR_FILE_RUNES:]
+	}
+	counter.(prometheus.ExemplarAdder).AddWithExemplar(1, prometheus.Labels{
+		"file": string(file),
+		"row":  strconv.Itoa(row),
+	})
+}
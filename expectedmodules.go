@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// moduleCountMismatch is only registered with --expected-modules.
+var moduleCountMismatch = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tigo_module_count_mismatch",
+		Help: help("tigo_module_count_mismatch", "Whether the number of modules in the CSV header differs from --expected-modules, with the number found as label"),
+	},
+	[]string{"detected"},
+)
+
+// moduleCountCheck compares the number of modules of every file read with
+// the number the array is known to have.
+type moduleCountCheck struct {
+	expected int
+	detected int
+}
+
+func (c *moduleCountCheck) update(detected int) {
+	if detected == c.detected {
+		return
+	}
+	c.detected = detected
+	moduleCountMismatch.Reset()
+	if detected == c.expected {
+		moduleCountMismatch.WithLabelValues(strconv.Itoa(detected)).Set(0)
+		return
+	}
+	log.Printf("WARNING: the CSV file has %d modules, expected %d", detected, c.expected)
+	moduleCountMismatch.WithLabelValues(strconv.Itoa(detected)).Set(1)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// moduleSignal is a module column exported besides power, volts, RSSI and
+// temperature.
+type moduleSignal struct {
+	field  string
+	offset int
+	gauge  *prometheus.GaugeVec
+}
+
+// extraSignals returns the module columns exported besides the usual four.
+// It needs the gauges of registerMetrics.
+func extraSignals() []moduleSignal {
+	return []moduleSignal{
+		{"volts_out", VOUT_OFFSET, moduleVoltsOut},
+		{"current_in", IIN_OFFSET, moduleCurrentIn},
+		{"duty_cycle", PWM_OFFSET, moduleDutyCycle},
+	}
+}
+
+// findBypassColumns looks up the bypass column of every module by header
+// name, as findQualityColumns does: a module whose Vin column is
+// "LMU_Garage_3_Vin" has its bypass status in "LMU_Garage_3_Bypass" if the
+// firmware logs one. Modules without one are left out.
+func findBypassColumns(headers []string, moduleCount int) map[int]int {
+	byName := make(map[string]int, len(headers))
+	for i, h := range headers {
+		byName[strings.ToLower(h)] = i
+	}
+
+	columns := make(map[int]int)
+	for moduleIndex := 1; moduleIndex <= moduleCount; moduleIndex++ {
+		vinColumn := LEADING_COLUMNS + (moduleIndex-1)*MODULE_COLUMNS + VIN_OFFSET
+		if vinColumn >= len(headers) {
+			break
+		}
+		vin := strings.ToLower(headers[vinColumn])
+		if !strings.HasSuffix(vin, "vin") {
+			continue
+		}
+		if i, ok := byName[strings.TrimSuffix(vin, "vin")+"bypass"]; ok {
+			columns[moduleIndex] = i
+		}
+	}
+	return columns
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	FILE_INDEX_VERSION    = 1
+	FILE_INDEX_SAVE_EVERY = 10 * time.Minute
+)
+
+// fileIndex is an on-disk record of the data directory tree, used to make the
+// first walk after a restart cheap on large archives.
+type fileIndex struct {
+	Version int                    `json:"version"`
+	Dirs    map[string]*indexedDir `json:"dirs"`
+}
+
+// indexedDir is the listing of one directory, in lexical order, as it was
+// when the directory had ModTime.
+type indexedDir struct {
+	ModTime time.Time      `json:"mtime"`
+	Entries []indexedEntry `json:"entries"`
+}
+
+type indexedEntry struct {
+	Name    string    `json:"name"`
+	Dir     bool      `json:"dir,omitempty"`
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+}
+
+// loadFileIndex reads an index written by save. Any problem with the file
+// just means there is no index to use; it is never fatal.
+func loadFileIndex(path string) *fileIndex {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Ignoring file index %s: %v", path, err)
+		}
+		return nil
+	}
+
+	var idx fileIndex
+	if err := json.Unmarshal(data, &idx); err != nil || idx.Version != FILE_INDEX_VERSION || idx.Dirs == nil {
+		log.Printf("Ignoring unusable file index %s", path)
+		return nil
+	}
+	return &idx
+}
+
+// save writes the index atomically so a crash never leaves a torn file.
+func (idx *fileIndex) save(path string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// walkIndexed ranks the CSV files under dataDir with the same rules as
+// rankCSVFiles, including skipping files limits does not accept, and returns
+// them together with a fresh index of the tree.
+// Directories whose mtime matches prev are not re-listed; subdirectories are
+// always checked. Appending to a file does not change its directory's mtime,
+// so the CSV files of such directories are still stated, which is cheap next
+// to reading the directory. Even so, the caller should only pass a prev index
+// for the first walk after startup and walk fully afterwards.
+func walkIndexed(dataDir string, prev *fileIndex, limits *fileLimits) ([]fileStat, *fileIndex, error) {
+	next := &fileIndex{Version: FILE_INDEX_VERSION, Dirs: make(map[string]*indexedDir)}
+	var found []fileStat
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+
+		var listing *indexedDir
+		reused := false
+		if prev != nil {
+			if c, ok := prev.Dirs[dir]; ok && c.ModTime.Equal(info.ModTime()) {
+				listing, reused = c, true
+			}
+		}
+		if listing == nil {
+			listing, err = listDir(dir, info.ModTime())
+			if err != nil {
+				return err
+			}
+		}
+		next.Dirs[dir] = listing
+
+		for i, entry := range listing.Entries {
+			path := filepath.Join(dir, entry.Name)
+			if entry.Dir {
+				if err := walk(path); err != nil {
+					return err
+				}
+				continue
+			}
+			if filepath.Ext(entry.Name) != ".csv" {
+				continue
+			}
+			if reused {
+				info, err := os.Stat(path)
+				if err != nil {
+					// Gone since: handled when it is read, as
+					// for a file that vanishes after any walk.
+					continue
+				}
+				listing.Entries[i].ModTime = info.ModTime()
+				listing.Entries[i].Size = info.Size()
+				entry = listing.Entries[i]
+			}
+			found = append(found, fileStat{Path: path, ModTime: entry.ModTime, Size: entry.Size})
+		}
+		return nil
+	}
+
+	if err := walk(dataDir); err != nil {
+		return nil, nil, err
+	}
+
+	return rankCandidates(dataDir, found, limits), next, nil
+}
+
+// listDir reads the entries of dir, stating files for their mtime and size.
+func listDir(dir string, modTime time.Time) (*indexedDir, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	listing := &indexedDir{ModTime: modTime}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		entry := indexedEntry{Name: e.Name(), Dir: info.IsDir()}
+		if !entry.Dir {
+			entry.ModTime = info.ModTime()
+			entry.Size = info.Size()
+		}
+		listing.Entries = append(listing.Entries, entry)
+	}
+	return listing, nil
+}
+
+// indexedWalker ranks the CSV files using a persistent file index: the
+// index loaded at startup speeds up the first walk, and later full walks
+// refresh it on disk every FILE_INDEX_SAVE_EVERY.
+type indexedWalker struct {
+	path     string
+	prev     *fileIndex
+	lastSave time.Time
+}
+
+func newIndexedWalker(path string) *indexedWalker {
+	return &indexedWalker{path: path, prev: loadFileIndex(path)}
+}
+
+func (w *indexedWalker) rank(dataDir string, limits *fileLimits) ([]fileStat, error) {
+	ranked, idx, err := walkIndexed(dataDir, w.prev, limits)
+	if err != nil {
+		return nil, err
+	}
+	w.prev = nil
+
+	if time.Since(w.lastSave) >= FILE_INDEX_SAVE_EVERY {
+		if err := idx.save(w.path); err != nil {
+			log.Printf("Error saving file index: %v", err)
+		}
+		w.lastSave = time.Now()
+	}
+	return ranked, nil
+}
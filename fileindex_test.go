@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWalkIndexedSeesAppendsToCachedFiles(t *testing.T) {
+	dataDir := t.TempDir()
+	day := filepath.Join(dataDir, "2024-06-01")
+	if err := os.Mkdir(day, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 10; i++ {
+		path := filepath.Join(day, fmt.Sprintf("%02d.csv", i))
+		paths = append(paths, path)
+		if err := os.WriteFile(path, []byte("header\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, idx, err := walkIndexed(dataDir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The 9th newest file is appended to while the exporter is down: its
+	// directory keeps its mtime.
+	older := paths[1]
+	dirInfo, err := os.Stat(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, older, []byte("row\n"))
+	if err := os.Chtimes(older, base.Add(20*time.Minute), base.Add(20*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(day, dirInfo.ModTime(), dirInfo.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	ranked, _, err := walkIndexed(dataDir, idx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranked) != len(paths) || ranked[0].Path != older {
+		t.Fatalf("got %v, want %s first", ranked, older)
+	}
+	if ranked[0].Size != int64(len("header\nrow\n")) {
+		t.Errorf("got size %d of the appended file, want it stated again", ranked[0].Size)
+	}
+}
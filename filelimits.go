@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// A day of 10 s rows for a few hundred modules is well under 100 MB.
+	DEFAULT_MAX_FILE_MB = 256
+	DEFAULT_MAX_LINE_KB = 1024
+)
+
+var errLineTooLong = errors.New("CSV line exceeds the maximum line length")
+
+var rejectedFiles = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tigo_rejected_files_total",
+		Help: help("tigo_rejected_files_total", "Number of candidate CSV files skipped as too large or with too long a line"),
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	rejectedFiles.WithLabelValues("size").Add(0)
+	rejectedFiles.WithLabelValues("line_length").Add(0)
+	prometheus.MustRegister(rejectedFiles)
+}
+
+// fileLimits keeps files that are too big to be DAQS data, typically garbage
+// left by filesystem corruption, from being read into memory. Rejected files
+// are skipped when looking for the newest file, so the next newest one is
+// used instead, until they shrink again (a rotation or truncation).
+type fileLimits struct {
+	maxSize int64
+	maxLine int
+
+	mu       sync.Mutex
+	rejected map[string]int64
+}
+
+func newFileLimits(maxFileMB, maxLineKB int) *fileLimits {
+	return &fileLimits{
+		maxSize:  int64(maxFileMB) << 20,
+		maxLine:  maxLineKB << 10,
+		rejected: make(map[string]int64),
+	}
+}
+
+// accept reports whether a candidate file of size may be read. A nil
+// fileLimits accepts everything.
+func (l *fileLimits) accept(path string, size int64) bool {
+	if l == nil {
+		return true
+	}
+	if size > l.maxSize {
+		l.reject(path, size, "size")
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rejectedSize, ok := l.rejected[path]
+	if ok && size < rejectedSize {
+		delete(l.rejected, path)
+		return true
+	}
+	return !ok
+}
+
+// reject excludes path from being read until it shrinks below size.
+func (l *fileLimits) reject(path string, size int64, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.rejected[path]; ok {
+		return
+	}
+	l.rejected[path] = size
+	rejectedFiles.WithLabelValues(reason).Inc()
+	log.Printf("Skipping %s (%d bytes): %s limit exceeded", path, size, reason)
+}
+
+// checkLineLength reports errLineTooLong if any line of data, including an
+// unterminated last one, is longer than maxLine bytes. maxLine 0 disables
+// the check.
+func checkLineLength(data []byte, maxLine int) error {
+	if maxLine <= 0 {
+		return nil
+	}
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			end = len(data)
+		}
+		if end > maxLine {
+			return errLineTooLong
+		}
+		data = data[min(end+1, len(data)):]
+	}
+	return nil
+}
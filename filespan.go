@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The file span gauges are only registered with --file-timespan.
+var (
+	fileFirstTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tigo_file_first_timestamp",
+			Help: help("tigo_file_first_timestamp", "Timestamp of the first record of the file being read"),
+		},
+	)
+	fileLastTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tigo_file_last_timestamp",
+			Help: help("tigo_file_last_timestamp", "Timestamp of the last record of the file being read"),
+		},
+	)
+)
+
+// fileSpan tracks the time span covered by the file being read. The first
+// record is read separately, once per file, since incremental reads only
+// ever see the end of it.
+type fileSpan struct {
+	opts csvOptions
+	path string
+	size int64
+}
+
+// update reads the first record of path if it has not been read since the
+// file was last replaced or truncated.
+func (s *fileSpan) update(src dataSource, path string, size int64) error {
+	if path == s.path && size >= s.size {
+		s.size = size
+		return nil
+	}
+
+	file, err := src.Open(path, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rdr := s.opts.newReader(file)
+	rdr.FieldsPerRecord = -1
+	if s.opts.NoHeader == nil {
+		if _, err := rdr.Read(); err != nil {
+			return fmt.Errorf("reading CSV headers: %w", err)
+		}
+	}
+	record, err := rdr.Read()
+	if err != nil {
+		return fmt.Errorf("reading first CSV record: %w", err)
+	}
+	s.opts.clean(record)
+	if s.opts.NoHeader != nil {
+		record = []string{TIMESTAMP_COLUMN: record[min(s.opts.NoHeader.timestamp, len(record)-1)]}
+	}
+	ts, err := recordTimestamp(record)
+	if err != nil {
+		return fmt.Errorf("first CSV record: %w", err)
+	}
+
+	fileFirstTimestamp.Set(ts)
+	s.path, s.size = path, size
+	return nil
+}
+
+// observeLast publishes the timestamp of the last of records, if any.
+func (s *fileSpan) observeLast(records [][]string) {
+	if len(records) == 0 {
+		return
+	}
+	if ts, err := recordTimestamp(records[len(records)-1]); err == nil {
+		fileLastTimestamp.Set(ts)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexflint/go-arg"
+)
+
+const (
+	DEFAULT_FIXTURE_ROWS = 10
+)
+
+// Layouts tried when shifting the human-readable date column of a DAQS row.
+var fixtureDateLayouts = []string{
+	"2006/01/02 15:04:05.000",
+	"2006/01/02 15:04:05",
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+}
+
+type FixtureConfig struct {
+	TigoDAQSDataDir string `arg:"positional"`
+	Output          string `arg:"--output,help:file to write the fixture to: default(stdout)"`
+	Rows            int    `arg:"--rows,help:number of trailing records to keep: default(10)"`
+	ShiftDays       int    `arg:"--shift-days,help:days to shift timestamps back by: default(random)"`
+	LazyQuotes      bool   `arg:"--lazy-quotes,help:tolerate stray quotes in CSV fields"`
+}
+
+// recordFixture implements the record-fixture subcommand: it takes the newest
+// CSV in the data directory, keeps its header and last few records and writes
+// a scrubbed copy that is safe to attach to a bug report.
+func recordFixture(args []string) {
+	var cfg FixtureConfig
+	p, err := arg.NewParser(arg.Config{Program: "tigo-exporter record-fixture"}, &cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	switch err := p.Parse(args); {
+	case err == arg.ErrHelp:
+		p.WriteHelp(os.Stdout)
+		os.Exit(0)
+	case err != nil:
+		p.Fail(err.Error())
+	}
+
+	if cfg.TigoDAQSDataDir == "" {
+		cfg.TigoDAQSDataDir = DAQS_DIR
+	}
+	if cfg.Rows <= 0 {
+		cfg.Rows = DEFAULT_FIXTURE_ROWS
+	}
+	if cfg.ShiftDays == 0 {
+		cfg.ShiftDays = 30 + rand.Intn(365)
+	}
+
+	csvFile, err := getNewestCSVFile(cfg.TigoDAQSDataDir, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting newest CSV file: %v\n", err)
+		os.Exit(1)
+	}
+	if csvFile == "" {
+		fmt.Fprintf(os.Stderr, "No CSV file found in %s\n", cfg.TigoDAQSDataDir)
+		os.Exit(1)
+	}
+
+	file, err := os.Open(csvFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to open CSV file: %v\n", err)
+		os.Exit(1)
+	}
+	original, err := parseDAQS(file, csvFile, csvOptions{LazyQuotes: cfg.LazyQuotes})
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing CSV file: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(original.Records) > cfg.Rows {
+		original.Records = original.Records[len(original.Records)-cfg.Rows:]
+	}
+
+	scrubbed := scrubDAQS(original, time.Duration(cfg.ShiftDays)*24*time.Hour)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := append(scrubbed.Headers, make([]string, scrubbed.Width-len(scrubbed.Headers))...)
+	w.Write(header)
+	w.WriteAll(scrubbed.Records)
+	if err := w.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing fixture: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The fixture is only useful if the exporter sees the same thing in it as
+	// in the original, so re-parse what we are about to write.
+	roundTrip, err := parseDAQS(bytes.NewReader(buf.Bytes()), "", csvOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Scrubbed fixture does not parse: %v\n", err)
+		os.Exit(1)
+	}
+	if err := compareDAQSStructure(original, roundTrip); err != nil {
+		fmt.Fprintf(os.Stderr, "Scrubbed fixture differs from %s: %v\n", csvFile, err)
+		os.Exit(1)
+	}
+
+	if cfg.Output == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(cfg.Output, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing fixture: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d records from %s to %s (timestamps shifted back %d days)\n",
+		len(scrubbed.Records), csvFile, cfg.Output, cfg.ShiftDays)
+}
+
+// scrubDAQS returns a copy of d with module names replaced by generated ones,
+// serials replaced and timestamps shifted back by shift.
+func scrubDAQS(d *daqsFile, shift time.Duration) *daqsFile {
+	out := &daqsFile{
+		Headers:     make([]string, len(d.Headers)),
+		ModuleCount: d.ModuleCount,
+		Records:     make([][]string, 0, len(d.Records)),
+		Width:       d.Width,
+	}
+
+	copy(out.Headers, d.Headers)
+	for i := LEADING_COLUMNS; i < len(out.Headers); i++ {
+		moduleIndex := (i-LEADING_COLUMNS)/MODULE_COLUMNS + 1
+		out.Headers[i] = scrubHeader(out.Headers[i], moduleIndex, (i-LEADING_COLUMNS)%MODULE_COLUMNS)
+	}
+
+	for _, record := range d.Records {
+		row := make([]string, len(record))
+		copy(row, record)
+
+		for i := range row {
+			switch {
+			case i == TIMESTAMP_COLUMN:
+				row[i] = shiftUnixField(row[i], shift)
+			case i < LEADING_COLUMNS:
+				row[i] = shiftDateField(row[i], shift)
+			case (i-LEADING_COLUMNS)%MODULE_COLUMNS == ID_OFFSET && row[i] != "":
+				row[i] = fmt.Sprintf("%08X", (i-LEADING_COLUMNS)/MODULE_COLUMNS+1)
+			}
+		}
+		out.Records = append(out.Records, row)
+	}
+
+	return out
+}
+
+// scrubHeader replaces everything before the signal suffix of a module
+// header cell (e.g. the "Garage_3" in "Garage_3_Vin") with a generated name.
+func scrubHeader(header string, moduleIndex int, offset int) string {
+	sep := strings.LastIndexAny(header, "_ .")
+	if sep < 0 {
+		return fmt.Sprintf("A%d_%d", moduleIndex, offset)
+	}
+	return fmt.Sprintf("A%d%s", moduleIndex, header[sep:])
+}
+
+func shiftUnixField(field string, shift time.Duration) string {
+	value, err := getFieldValue(field)
+	if err != nil {
+		return field
+	}
+	return strconv.FormatFloat(value-shift.Seconds(), 'f', -1, 64)
+}
+
+func shiftDateField(field string, shift time.Duration) string {
+	for _, layout := range fixtureDateLayouts {
+		t, err := time.Parse(layout, field)
+		if err == nil {
+			return t.Add(-shift).Format(layout)
+		}
+	}
+	return field
+}
+
+// compareDAQSStructure checks that b has the same shape as a as far as the
+// exporter is concerned: column and module counts, record count, and which of
+// the exported fields parse in every record.
+func compareDAQSStructure(a, b *daqsFile) error {
+	if len(a.Headers) != len(b.Headers) {
+		return fmt.Errorf("header has %d columns, want %d", len(b.Headers), len(a.Headers))
+	}
+	if a.ModuleCount != b.ModuleCount {
+		return fmt.Errorf("module count is %d, want %d", b.ModuleCount, a.ModuleCount)
+	}
+	if len(a.Records) != len(b.Records) {
+		return fmt.Errorf("record count is %d, want %d", len(b.Records), len(a.Records))
+	}
+
+	offsets := []int{VIN_OFFSET, TEMP_OFFSET, RSSI_OFFSET, PIN_OFFSET}
+	for r := range a.Records {
+		if len(a.Records[r]) != len(b.Records[r]) {
+			return fmt.Errorf("record %d has %d columns, want %d", r, len(b.Records[r]), len(a.Records[r]))
+		}
+
+		columns := []int{TIMESTAMP_COLUMN}
+		for i := 0; i < a.ModuleCount; i++ {
+			for _, offset := range offsets {
+				columns = append(columns, LEADING_COLUMNS+i*MODULE_COLUMNS+offset)
+			}
+		}
+
+		for _, c := range columns {
+			if c >= len(a.Records[r]) {
+				continue
+			}
+			_, errA := getFieldValue(a.Records[r][c])
+			_, errB := getFieldValue(b.Records[r][c])
+			if (errA == nil) != (errB == nil) {
+				return fmt.Errorf("record %d column %d parses differently", r, c)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative tigo.proto
+
+import (
+	"log"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// snapshotStream fans published snapshots out to gRPC subscribers. Each
+// subscriber has a one-slot queue that always holds the newest snapshot it
+// has not been sent yet, so a slow client never holds up the reader.
+type snapshotStream struct {
+	UnimplementedExporterServer
+
+	mu          sync.Mutex
+	latest      *Snapshot
+	subscribers map[chan *Snapshot]bool
+}
+
+func newSnapshotStream() *snapshotStream {
+	return &snapshotStream{subscribers: make(map[chan *Snapshot]bool)}
+}
+
+// serve accepts gRPC connections on address until the listener fails.
+func (s *snapshotStream) serve(address string) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatalf("Error listening for gRPC on %s: %v", address, err)
+	}
+	server := grpc.NewServer()
+	RegisterExporterServer(server, s)
+	log.Printf("Serving gRPC on %s", address)
+	log.Fatal(server.Serve(lis))
+}
+
+// publish sends a copy of snap to every subscriber.
+func (s *snapshotStream) publish(snap *snapshot) {
+	msg := &Snapshot{
+		File:         snap.File,
+		FileModified: snap.FileModified.Unix(),
+		Timestamp:    snap.Timestamp,
+	}
+	for _, m := range snap.Modules {
+		fields := make(map[string]float64, len(m.Values))
+		for k, v := range m.Values {
+			fields[k] = v
+		}
+		msg.Modules = append(msg.Modules, &Module{Name: m.Name, Fields: fields})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latest = msg
+	for ch := range s.subscribers {
+		offerLatest(ch, msg)
+	}
+}
+
+// offerLatest puts msg into the one-slot ch, replacing what is queued there.
+func offerLatest(ch chan *Snapshot, msg *Snapshot) {
+	for {
+		select {
+		case ch <- msg:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+func (s *snapshotStream) Subscribe(_ *SubscribeRequest, stream Exporter_SubscribeServer) error {
+	ch := make(chan *Snapshot, 1)
+	s.mu.Lock()
+	if s.latest != nil {
+		ch <- s.latest
+	}
+	s.subscribers[ch] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case msg := <-ch:
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// fixedLayout describes the columns of DAQS files without a header row, as
+// given with --no-header and --layout. Records are rearranged into the usual
+// layout with synthesized headers, so the rest of the exporter reads them
+// like any other file; modules are named A1, A2, ...
+type fixedLayout struct {
+	leading   int
+	module    int
+	count     int
+	timestamp int
+	// offsets maps the offset of a column in the usual module block to its
+	// offset in the file's.
+	offsets map[int]int
+}
+
+// parseFixedLayout parses --layout KEY=VALUE settings: leading, module and
+// count for the number of leading columns, columns per module and modules
+// (default: as many as fit), timestamp for the column of the unix time and
+// the lower case name of a module column (vin, temp, rssi, pin, ...) for its
+// offset within a module. Unset values are those of the usual layout.
+func parseFixedLayout(specs []string) (*fixedLayout, error) {
+	l := &fixedLayout{
+		leading:   LEADING_COLUMNS,
+		module:    MODULE_COLUMNS,
+		timestamp: TIMESTAMP_COLUMN,
+		offsets:   make(map[int]int),
+	}
+	columns := make(map[string]int, MODULE_COLUMNS)
+	for offset, name := range daqsColumnNames {
+		columns[strings.ToLower(name)] = offset
+	}
+
+	explicit := false
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid --layout %q: want KEY=N", spec)
+		}
+		switch key = strings.ToLower(strings.TrimSpace(key)); key {
+		case "leading":
+			l.leading = n
+		case "module":
+			l.module = n
+		case "count":
+			l.count = n
+		case "timestamp":
+			l.timestamp = n
+		default:
+			offset, ok := columns[key]
+			if !ok {
+				return nil, fmt.Errorf("invalid --layout %q: unknown key %q", spec, key)
+			}
+			l.offsets[offset] = n
+			explicit = true
+		}
+	}
+	if l.module == 0 {
+		return nil, fmt.Errorf("invalid --layout: module must be at least 1")
+	}
+	if !explicit {
+		for offset := 0; offset < MODULE_COLUMNS && offset < l.module; offset++ {
+			l.offsets[offset] = offset
+		}
+	}
+	for offset, n := range l.offsets {
+		if n >= l.module {
+			return nil, fmt.Errorf("invalid --layout: %s offset %d is not within a module of %d columns",
+				strings.ToLower(daqsColumnNames[offset]), n, l.module)
+		}
+	}
+	return l, nil
+}
+
+// channelLayout returns the rearrangement of records width fields wide.
+func (l *fixedLayout) channelLayout(width int) (*channelLayout, error) {
+	count := l.count
+	if count == 0 {
+		count = (width - l.leading) / l.module
+	}
+	if need := l.leading + count*l.module; need > width || count == 0 {
+		return nil, fmt.Errorf("records have %d fields, too few for the layout", width)
+	}
+
+	layout := &channelLayout{sourceWidth: width}
+	for c := 0; c < LEADING_COLUMNS; c++ {
+		source := -1
+		if c == TIMESTAMP_COLUMN {
+			source = l.timestamp
+		}
+		layout.columns = append(layout.columns, source)
+	}
+	for m := 0; m < count; m++ {
+		for offset := 0; offset < MODULE_COLUMNS; offset++ {
+			source := -1
+			if n, ok := l.offsets[offset]; ok {
+				source = l.leading + m*l.module + n
+			}
+			layout.columns = append(layout.columns, source)
+		}
+		layout.names = append(layout.names, fmt.Sprintf("A%d", m+1))
+	}
+	return layout, nil
+}
+
+// headers synthesizes the header row of the rearranged records.
+func (l *fixedLayout) headers(layout *channelLayout) []string {
+	headers := []string{"DataTime", "Unix Time", "Status"}
+	for _, name := range layout.names {
+		for _, column := range daqsColumnNames {
+			headers = append(headers, name+"_"+column)
+		}
+	}
+	return headers
+}
+
+// parseHeaderless reads the records of a file without a header row.
+func parseHeaderless(rdr *csv.Reader, name string, opts csvOptions) (*daqsFile, error) {
+	records, stoppedAt, err := readRecords(rdr, name, 1)
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV records: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("reading CSV records: %w", io.EOF)
+	}
+	opts.clean(records...)
+
+	layout, err := opts.NoHeader.channelLayout(len(records[0]))
+	if err != nil {
+		return nil, err
+	}
+	layout.applyAll(records)
+	return &daqsFile{
+		Headers:     opts.NoHeader.headers(layout),
+		ModuleCount: len(layout.names),
+		Records:     records,
+		Width:       len(layout.columns),
+		Layout:      layout,
+		FirstRow:    1,
+		StoppedAt:   stoppedAt,
+	}, nil
+}
+
+// warnIfHeaderless logs a warning when what was read as the header row has a
+// number in the timestamp column, as the data rows of a file stripped of its
+// header do.
+func warnIfHeaderless(headers []string) {
+	if TIMESTAMP_COLUMN >= len(headers) {
+		return
+	}
+	if _, err := strconv.ParseFloat(headers[TIMESTAMP_COLUMN], 64); err == nil {
+		log.Printf("WARNING: the header row of the CSV file looks like a data row (%q); if the file has no header use --no-header", headers[TIMESTAMP_COLUMN])
+	}
+}
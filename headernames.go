@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// unparsedHeaders remembers the header cells headerLayout has logged, so a
+// file read again from the top does not log them every time.
+var unparsedHeaders sync.Map
+
+// splitModuleHeader splits a module column header such as "B1 Vin" or
+// "LMU_Garage_3_Pin" into the module name and the signal, less the LMU_
+// prefix the CCA puts before the name. The name is empty for a header with
+// a blank one, such as "_Vin".
+func splitModuleHeader(header string) (name, signal string, ok bool) {
+	sep := strings.LastIndexAny(header, "_ .")
+	if sep < 0 || sep == len(header)-1 {
+		return "", "", false
+	}
+	name = strings.TrimSpace(header[:sep])
+	if len(name) > 4 && strings.EqualFold(name[:4], "lmu_") {
+		name = name[4:]
+	}
+	return name, strings.ToLower(header[sep+1:]), true
+}
+
+// headerColumns is what headerLayout found of one module.
+type headerColumns struct {
+	block      []int
+	vin2, pin2 int
+}
+
+// leadingNames maps the normalized headers of the leading columns to their
+// position in the usual layout.
+var leadingNames = map[string]int{
+	"datatime": 0,
+	"datetime": 0,
+	"unixtime": TIMESTAMP_COLUMN,
+	"status":   2,
+}
+
+// headerLayout returns the layout of a file with headers, or nil if no
+// column names a module signal. Rather than by position, columns are
+// assigned by their header: "B1 Vin" is the Vin of module B1 wherever it is
+// in the row, so firmware with more columns per module or more leading
+// columns is read right. The leading columns are the ones before the first
+// module column, placed by name where it is known ("Unix Time" ...) and by
+// position otherwise. A signal a module has no column for is left empty, so
+// its series is not set.
+//
+// Modules are ordered as they first appear and named A1, A2, ... or, with
+// named, as in the header (by position where the header leaves the name
+// blank). Columns that are not one of the usual signals of a module (SNR,
+// Noise, ...) or leading columns left over are kept after the module blocks
+// as they are. A header cell that does not split into a module name and a
+// signal is logged once. Dual-input units are split as detectChannels does,
+// into NAME.1 and NAME.2.
+func headerLayout(headers []string, width int, named bool) *channelLayout {
+	offsets := make(map[string]int, MODULE_COLUMNS)
+	for offset, signal := range daqsColumnNames {
+		offsets[strings.ToLower(signal)] = offset
+	}
+	isModuleColumn := func(header string) bool {
+		_, signal, ok := splitModuleHeader(header)
+		_, known := offsets[signal]
+		return ok && (known || signal == "vin2" || signal == "pin2")
+	}
+
+	first := 0
+	for first < len(headers) && !isModuleColumn(headers[first]) {
+		first++
+	}
+	if first == len(headers) {
+		return nil
+	}
+
+	leading := make([]int, LEADING_COLUMNS)
+	for i := range leading {
+		leading[i] = -1
+	}
+	placed := make(map[int]bool)
+	for c := 0; c < first; c++ {
+		key := strings.NewReplacer(" ", "", "_", "").Replace(strings.ToLower(headers[c]))
+		if i, ok := leadingNames[key]; ok && leading[i] < 0 {
+			leading[i] = c
+			placed[c] = true
+		}
+	}
+	for i := range leading {
+		if leading[i] < 0 && i < first && !placed[i] {
+			leading[i] = i
+			placed[i] = true
+		}
+	}
+	var extra []int
+	for c := 0; c < first; c++ {
+		if !placed[c] {
+			extra = append(extra, c)
+		}
+	}
+
+	var order []string
+	modules := make(map[string]*headerColumns)
+	// blockColumns counts the columns of module blocks so far, which tells
+	// the position of a module without a name.
+	blockColumns := 0
+	for c := first; c < len(headers); c++ {
+		name, signal, ok := splitModuleHeader(headers[c])
+		offset, known := offsets[signal]
+		if !ok || (!known && signal != "vin2" && signal != "pin2") {
+			if !ok {
+				if _, logged := unparsedHeaders.LoadOrStore(headers[c], true); !logged {
+					log.Printf("Column %d is not a module column: header %q does not name a module and signal", c+1, headers[c])
+				}
+			}
+			extra = append(extra, c)
+			continue
+		}
+		if name == "" {
+			// Without a name in the header the module is named by
+			// position, as it would be with --positional-names. Vin2
+			// and Pin2 follow the block of their unit.
+			position := blockColumns / MODULE_COLUMNS
+			if !known {
+				position = (blockColumns - 1) / MODULE_COLUMNS
+			}
+			name = fmt.Sprintf("A%d", position+1)
+		}
+		if known {
+			blockColumns++
+		}
+
+		m := modules[name]
+		if m == nil {
+			m = &headerColumns{block: make([]int, MODULE_COLUMNS), vin2: -1, pin2: -1}
+			for i := range m.block {
+				m.block[i] = -1
+			}
+			modules[name] = m
+			order = append(order, name)
+		}
+		switch {
+		case signal == "vin2":
+			m.vin2 = c
+		case signal == "pin2":
+			m.pin2 = c
+		default:
+			m.block[offset] = c
+		}
+	}
+
+	layout := &channelLayout{sourceWidth: width}
+	layout.columns = append(layout.columns, leading...)
+	for i, name := range order {
+		if !named {
+			name = fmt.Sprintf("A%d", i+1)
+		}
+		m := modules[order[i]]
+		if m.vin2 < 0 || m.pin2 < 0 {
+			layout.columns = append(layout.columns, m.block...)
+			layout.names = append(layout.names, name)
+			continue
+		}
+		second := append([]int(nil), m.block...)
+		second[VIN_OFFSET] = m.vin2
+		second[PIN_OFFSET] = m.pin2
+		layout.columns = append(layout.columns, m.block...)
+		layout.columns = append(layout.columns, second...)
+		layout.names = append(layout.names, name+".1", name+".2")
+	}
+	layout.columns = append(layout.columns, extra...)
+	return layout
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHeaderLayoutUsualLayout(t *testing.T) {
+	headers := testHeader("LMU_Garage_1", "LMU_Garage_2")
+	layout := headerLayout(headers, len(headers), true)
+	if layout == nil {
+		t.Fatal("no layout for module headers")
+	}
+	if want := []string{"Garage_1", "Garage_2"}; !reflect.DeepEqual(layout.names, want) {
+		t.Errorf("got names %v, want %v", layout.names, want)
+	}
+	for i, c := range layout.columns {
+		if c != i {
+			t.Fatalf("column %d comes from %d; the usual layout must stay as it is", i, c)
+		}
+	}
+}
+
+func TestHeaderLayoutMoreColumnsPerModule(t *testing.T) {
+	// Firmware with an extra leading column before the timestamp, the
+	// signals of a module in another order and an SNR column of its own.
+	headers := []string{"Gateway", "Unix Time", "DataTime", "Status"}
+	for _, name := range []string{"B1", "B2"} {
+		for _, signal := range []string{"Pin", "Vin", "SNR", "Iin", "Temp", "Pwm", "Status", "Flags", "RSSI", "BRSSI", "ID", "Vout", "Details"} {
+			headers = append(headers, name+" "+signal)
+		}
+	}
+	layout := headerLayout(headers, len(headers), true)
+	if layout == nil {
+		t.Fatal("no layout for module headers")
+	}
+	if want := []string{"B1", "B2"}; !reflect.DeepEqual(layout.names, want) {
+		t.Errorf("got names %v, want %v", layout.names, want)
+	}
+
+	record := make([]string, len(headers))
+	for i, h := range headers {
+		record[i] = h
+	}
+	out := layout.apply(record)
+	checks := map[int]string{
+		0:                            "DataTime",
+		TIMESTAMP_COLUMN:             "Unix Time",
+		2:                            "Status",
+		LEADING_COLUMNS + VIN_OFFSET: "B1 Vin",
+		LEADING_COLUMNS + PIN_OFFSET: "B1 Pin",
+		LEADING_COLUMNS + MODULE_COLUMNS + IIN_OFFSET: "B2 Iin",
+		LEADING_COLUMNS + MODULE_COLUMNS + PIN_OFFSET: "B2 Pin",
+	}
+	for i, want := range checks {
+		if out[i] != want {
+			t.Errorf("column %d is %q, want %q", i, out[i], want)
+		}
+	}
+	// The leftover leading column and the SNR columns follow the blocks.
+	extras := out[LEADING_COLUMNS+2*MODULE_COLUMNS:]
+	if want := []string{"Gateway", "B1 SNR", "B2 SNR"}; !reflect.DeepEqual(extras, want) {
+		t.Errorf("got extra columns %v, want %v", extras, want)
+	}
+}
+
+func TestHeaderLayoutDualInput(t *testing.T) {
+	headers := append(testHeader("LMU_Roof_1"), "LMU_Roof_1_Vin2", "LMU_Roof_1_Pin2")
+	layout := headerLayout(headers, len(headers), true)
+	if want := []string{"Roof_1.1", "Roof_1.2"}; layout == nil || !reflect.DeepEqual(layout.names, want) {
+		t.Fatalf("got layout %+v, want modules %v", layout, want)
+	}
+	second := LEADING_COLUMNS + MODULE_COLUMNS
+	if layout.columns[second+VIN_OFFSET] != len(headers)-2 || layout.columns[second+PIN_OFFSET] != len(headers)-1 {
+		t.Errorf("second input reads Vin from %d and Pin from %d, want the Vin2 and Pin2 columns",
+			layout.columns[second+VIN_OFFSET], layout.columns[second+PIN_OFFSET])
+	}
+}
+
+func TestHeaderLayoutWithoutModuleColumns(t *testing.T) {
+	if layout := headerLayout([]string{"DataTime", "Unix Time", "Status", "Total"}, 4, true); layout != nil {
+		t.Errorf("got layout %+v for headers naming no module signal", layout)
+	}
+}
+
+func TestMixedStringsLabelledByHeader(t *testing.T) {
+	path := filepath.Join("testdata", "mixed_strings.csv")
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	daqs, err := parseDAQS(file, path, csvOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if daqs.Layout == nil {
+		t.Fatal("no layout for a file with module headers")
+	}
+
+	// As process does for the file.
+	defer func(names []string) { moduleNames = names }(moduleNames)
+	moduleNames = daqs.Layout.names
+
+	power := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tigo_test_power", Help: "test"}, []string{"name"})
+	last := daqs.Records[len(daqs.Records)-1]
+	for i := 1; i <= daqs.ModuleCount; i++ {
+		pin, err := moduleField(last, i, PIN_OFFSET)
+		if err != nil {
+			t.Fatalf("module %d: %v", i, err)
+		}
+		updateGauge(power, "power", i, pin, 0)
+	}
+
+	// The Comment column after B1 is kept aside rather than shifting the
+	// modules after it.
+	want := map[string]float64{"A1": 102, "A2": 103, "B1": 202, "B2": 203, "C1": 302}
+	if daqs.ModuleCount != len(want) {
+		t.Errorf("got %d modules %v, want %d", daqs.ModuleCount, daqs.Layout.names, len(want))
+	}
+	for name, pin := range want {
+		if got, ok := seriesValue(t, power, name); !ok || got != pin {
+			t.Errorf("%s: got %v, %v; want power %v", name, got, ok, pin)
+		}
+	}
+	if _, ok := seriesValue(t, power, "A3"); ok {
+		t.Error("module labelled by position")
+	}
+}
+
+func TestPositionalNames(t *testing.T) {
+	path := filepath.Join("testdata", "mixed_strings.csv")
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	daqs, err := parseDAQS(file, path, csvOptions{PositionalNames: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"A1", "A2", "A3", "A4", "A5"}; daqs.Layout == nil || !reflect.DeepEqual(daqs.Layout.names, want) {
+		t.Errorf("got layout %+v, want modules %v", daqs.Layout, want)
+	}
+}
+
+func TestHeaderLayoutBlankNames(t *testing.T) {
+	// Strings A, B and C, with the name left out of the second and fourth
+	// blocks and a column naming no module in between.
+	headers := testHeader("A1", "")
+	headers = append(headers, "Comment")
+	headers = append(headers, testHeader("B1", "", "C1")[LEADING_COLUMNS:]...)
+	layout := headerLayout(headers, len(headers), true)
+	if layout == nil {
+		t.Fatal("no layout for module headers")
+	}
+	if want := []string{"A1", "A2", "B1", "A4", "C1"}; !reflect.DeepEqual(layout.names, want) {
+		t.Errorf("got names %v, want %v", layout.names, want)
+	}
+	if c := layout.columns[LEADING_COLUMNS+3*MODULE_COLUMNS+PIN_OFFSET]; headers[c] != "_Pin" || c < len(headers)-2*MODULE_COLUMNS {
+		t.Errorf("Pin of A4 read from column %d %q, want the one of the fourth block", c, headers[c])
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const DEFAULT_WARMUP_SEC = 10
+
+// healthzHandler answers 200 while the data file was modified within
+// STALE_TIMEOUT as of the last read and 503 otherwise. Until the first read
+// or for warmup after started, whichever ends first, it answers 200
+// "starting" so probes do not fail a freshly started exporter.
+func healthzHandler(modified *atomic.Int64, started time.Time, warmup time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := modified.Load()
+		if ns == 0 {
+			if time.Since(started) < warmup {
+				fmt.Fprintln(w, "starting")
+				return
+			}
+			http.Error(w, "no data read", http.StatusServiceUnavailable)
+			return
+		}
+		if age := time.Since(time.Unix(0, ns)); age > STALE_TIMEOUT {
+			http.Error(w, fmt.Sprintf("stale: data is %s old", age.Round(time.Second)), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const HEARTBEAT_TIMEOUT = 5 * time.Second
+
+var heartbeatPings = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tigo_heartbeat_pings_total",
+		Help: help("tigo_heartbeat_pings_total", "Number of heartbeat pings by kind (success or fail) and result (ok or error)"),
+	},
+	[]string{"kind", "result"},
+)
+
+// heartbeat pings a dead man's switch (healthchecks.io and the like): its URL
+// after every successful parse, and URL/fail once when parses have failed
+// for longer than STALE_TIMEOUT. Pings are sent from a separate goroutine;
+// one still waiting when the next is due is replaced by it.
+type heartbeat struct {
+	success string
+	fail    string
+	client  *http.Client
+	pending chan string
+
+	lastSuccess time.Time
+	failing     bool
+}
+
+func newHeartbeat(rawURL, proxyURL string) (*heartbeat, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid --heartbeat-url %q: want an http or https URL", rawURL)
+	}
+	client, err := newHTTPClient(HEARTBEAT_TIMEOUT, "--heartbeat-proxy-url", proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	prometheus.MustRegister(heartbeatPings)
+
+	h := &heartbeat{
+		success:     rawURL,
+		fail:        strings.TrimRight(rawURL, "/") + "/fail",
+		client:      client,
+		pending:     make(chan string, 1),
+		lastSuccess: time.Now(),
+	}
+	go h.run()
+	return h, nil
+}
+
+// cycleDone is called after every refresh cycle with whether it parsed new
+// data.
+func (h *heartbeat) cycleDone(ok bool) {
+	switch {
+	case ok:
+		h.lastSuccess = time.Now()
+		h.failing = false
+		h.queue(h.success)
+	case !h.failing && time.Since(h.lastSuccess) > STALE_TIMEOUT:
+		h.failing = true
+		h.queue(h.fail)
+	}
+}
+
+func (h *heartbeat) queue(target string) {
+	select {
+	case <-h.pending:
+	default:
+	}
+	h.pending <- target
+}
+
+func (h *heartbeat) run() {
+	for target := range h.pending {
+		kind := "success"
+		if target == h.fail {
+			kind = "fail"
+		}
+		if err := h.ping(target); err != nil {
+			log.Printf("Heartbeat ping failed: %v", err)
+			heartbeatPings.WithLabelValues(kind, "error").Inc()
+			continue
+		}
+		heartbeatPings.WithLabelValues(kind, "ok").Inc()
+	}
+}
+
+func (h *heartbeat) ping(target string) error {
+	resp, err := h.client.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", redactURL(target), resp.Status)
+	}
+	return nil
+}
+
+// redactURL hides any password in target for logging.
+func redactURL(target string) string {
+	if u, err := url.Parse(target); err == nil {
+		return u.Redacted()
+	}
+	return target
+}
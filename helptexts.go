@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// metricHelp is the default help text of every metric the exporter can
+// export, by name, whether or not it is registered. Metrics add theirs by
+// calling help as they are constructed.
+var metricHelp = make(map[string]string)
+
+// help records def as the help text of the metric name and returns it. Most
+// metrics are constructed at init, before --help-text is parsed, so the
+// overrides are applied when gathering, by helpGatherer.
+func help(name, def string) string {
+	metricHelp[name] = def
+	return def
+}
+
+// helpGatherer replaces the help text of the metric families gathered from
+// the wrapped Gatherer with the --help-text overrides.
+type helpGatherer struct {
+	gatherer prometheus.Gatherer
+	texts    map[string]string
+}
+
+// newHelpGatherer wraps g to apply texts. Texts naming no known metric are an
+// error, so it must be called once every metric has been constructed.
+func newHelpGatherer(g prometheus.Gatherer, texts map[string]string) (prometheus.Gatherer, error) {
+	for name := range texts {
+		if _, ok := metricHelp[name]; !ok {
+			return nil, fmt.Errorf("--help-text names unknown metric %q", name)
+		}
+	}
+	if len(texts) == 0 {
+		return g, nil
+	}
+	return &helpGatherer{gatherer: g, texts: texts}, nil
+}
+
+func (hg *helpGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := hg.gatherer.Gather()
+	for _, mf := range families {
+		if text, ok := hg.texts[mf.GetName()]; ok {
+			mf.Help = proto.String(text)
+		}
+	}
+	return families, err
+}
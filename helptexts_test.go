@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHelpGathererOverridesMetricsConstructedAtInit(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(corruptRows, kafkaDroppedMessages)
+
+	g, err := newHelpGatherer(reg, map[string]string{"tigo_kafka_dropped_messages_total": "Dropped"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	families, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		want := metricHelp[mf.GetName()]
+		if mf.GetName() == "tigo_kafka_dropped_messages_total" {
+			want = "Dropped"
+		}
+		if mf.GetHelp() != want {
+			t.Errorf("%s has help %q, want %q", mf.GetName(), mf.GetHelp(), want)
+		}
+	}
+}
+
+func TestHelpGathererRejectsUnknownMetric(t *testing.T) {
+	if _, err := newHelpGatherer(prometheus.NewRegistry(), map[string]string{"tigo_no_such_metric": "x"}); err == nil {
+		t.Error("no error for an override of an unknown metric")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ccaLoad1Desc = prometheus.NewDesc(
+		"tigo_cca_load1", help("tigo_cca_load1", "One minute load average of the host"), nil, nil)
+	ccaMemoryFreeDesc = prometheus.NewDesc(
+		"tigo_cca_memory_free_bytes", help("tigo_cca_memory_free_bytes", "Memory available on the host in bytes"), nil, nil)
+	ccaFlashFreeDesc = prometheus.NewDesc(
+		"tigo_cca_flash_free_bytes", help("tigo_cca_flash_free_bytes", "Free space on the data filesystem in bytes"), nil, nil)
+	ccaUptimeDesc = prometheus.NewDesc(
+		"tigo_cca_uptime_seconds", help("tigo_cca_uptime_seconds", "Host uptime in seconds"), nil, nil)
+)
+
+// hostCollector exports health metrics of the machine the exporter runs on,
+// meant for running directly on the CCA. Values are read at scrape time;
+// a metric whose source is unavailable is left out and warned about once.
+type hostCollector struct {
+	dataDir string
+
+	mu     sync.Mutex
+	warned map[string]bool
+}
+
+func newHostCollector(dataDir string) *hostCollector {
+	return &hostCollector{dataDir: dataDir, warned: make(map[string]bool)}
+}
+
+func (c *hostCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ccaLoad1Desc
+	ch <- ccaMemoryFreeDesc
+	ch <- ccaFlashFreeDesc
+	ch <- ccaUptimeDesc
+}
+
+func (c *hostCollector) Collect(ch chan<- prometheus.Metric) {
+	c.emit(ch, ccaLoad1Desc, "load average", readLoad1)
+	c.emit(ch, ccaMemoryFreeDesc, "memory", readMemoryAvailable)
+	c.emit(ch, ccaFlashFreeDesc, "data filesystem", func() (float64, error) {
+		free, err := diskFree(c.dataDir)
+		return float64(free), err
+	})
+	c.emit(ch, ccaUptimeDesc, "uptime", readUptime)
+}
+
+func (c *hostCollector) emit(ch chan<- prometheus.Metric, desc *prometheus.Desc, what string, read func() (float64, error)) {
+	value, err := read()
+	if err != nil {
+		c.mu.Lock()
+		if !c.warned[what] {
+			c.warned[what] = true
+			log.Printf("Host %s metrics unavailable: %v", what, err)
+		}
+		c.mu.Unlock()
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+}
+
+func readLoad1() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty /proc/loadavg")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+func readUptime() (float64, error) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty /proc/uptime")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// readMemoryAvailable returns MemAvailable from /proc/meminfo, or MemFree on
+// kernels too old to report it.
+func readMemoryAvailable() (float64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = kb * 1024
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if v, ok := values["MemAvailable"]; ok {
+		return v, nil
+	}
+	if v, ok := values["MemFree"]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("no MemAvailable or MemFree in /proc/meminfo")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DEFAULT_IO_TIMEOUT_SEC = 30
+)
+
+var errIOTimeout = errors.New("I/O operation timed out")
+
+// ioGuard bounds how long the refresh loop waits on filesystem operations. A
+// hung operation (e.g. a stale NFS handle) is abandoned rather than
+// cancelled, so at most one is allowed to be outstanding: while it is still
+// blocked, further operations fail immediately instead of piling up more
+// stuck goroutines.
+type ioGuard struct {
+	timeout time.Duration
+	busy    atomic.Bool
+}
+
+// runIO runs fn on a separate goroutine and returns errIOTimeout if it does
+// not finish within g.timeout.
+func runIO[T any](g *ioGuard, fn func() (T, error)) (T, error) {
+	var zero T
+	if !g.busy.CompareAndSwap(false, true) {
+		return zero, errIOTimeout
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		// Cleared before the result is handed over, so the caller can
+		// run the next operation as soon as it has it.
+		g.busy.Store(false)
+		done <- result{value, err}
+	}()
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+		return zero, errIOTimeout
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// blockingReader blocks every Read until release is closed.
+type blockingReader struct {
+	release chan struct{}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	<-r.release
+	return 0, io.EOF
+}
+
+func TestRunIOTimesOutOnBlockingReader(t *testing.T) {
+	guard := &ioGuard{timeout: 20 * time.Millisecond}
+	reader := &blockingReader{release: make(chan struct{})}
+	finished := make(chan struct{})
+
+	_, err := runIO(guard, func() ([]byte, error) {
+		defer close(finished)
+		return io.ReadAll(reader)
+	})
+	if !errors.Is(err, errIOTimeout) {
+		t.Fatalf("got %v, want errIOTimeout", err)
+	}
+
+	// While the abandoned read is stuck, further operations fail at once
+	// rather than piling up goroutines.
+	start := time.Now()
+	if _, err := runIO(guard, func() (int, error) { return 1, nil }); !errors.Is(err, errIOTimeout) {
+		t.Fatalf("got %v while busy, want errIOTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > guard.timeout {
+		t.Errorf("busy guard waited %s, want an immediate failure", elapsed)
+	}
+
+	close(reader.release)
+	<-finished
+	deadline := time.Now().Add(time.Second)
+	for guard.busy.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("guard still busy after the read returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got, err := runIO(guard, func() (int, error) { return 2, nil }); err != nil || got != 2 {
+		t.Fatalf("got %d, %v after the read returned, want 2, nil", got, err)
+	}
+}
+
+func TestRunIOBackToBack(t *testing.T) {
+	guard := &ioGuard{timeout: time.Second}
+	for i := 0; i < 10000; i++ {
+		got, err := runIO(guard, func() (int, error) { return i, nil })
+		if err != nil || got != i {
+			t.Fatalf("call %d: got %d, %v", i, got, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+const (
+	DEFAULT_KAFKA_BUFFER    = 10000
+	KAFKA_BATCH_SIZE        = 100
+	KAFKA_WRITE_TIMEOUT     = 10 * time.Second
+	KAFKA_MAX_ATTEMPTS      = 5
+	KAFKA_KEY_SITE_MODULE   = "site-module"
+	KAFKA_KEY_MODULE        = "module"
+	KAFKA_KEY_NONE          = "none"
+	KAFKA_RETRY_BACKOFF_MAX = 30 * time.Second
+)
+
+var (
+	kafkaDeliveryFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tigo_kafka_delivery_failures_total",
+			Help: help("tigo_kafka_delivery_failures_total", "Number of failed attempts to deliver a batch of messages to Kafka"),
+		},
+	)
+	kafkaDroppedMessages = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tigo_kafka_dropped_messages_total",
+			Help: help("tigo_kafka_dropped_messages_total", "Number of messages dropped because the buffer was full or retries were exhausted"),
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(kafkaDeliveryFailures)
+	prometheus.MustRegister(kafkaDroppedMessages)
+}
+
+// kafkaMessage is the JSON payload published for one module in one row.
+type kafkaMessage struct {
+	Timestamp float64            `json:"timestamp"`
+	Site      string             `json:"site,omitempty"`
+	Module    string             `json:"module"`
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Fields    map[string]float64 `json:"fields"`
+}
+
+// kafkaPublisher publishes every parsed row to Kafka. Messages are queued in
+// a bounded buffer and sent from a separate goroutine, so a slow or
+// unreachable broker never blocks the refresh loop; when the buffer is full
+// new messages are dropped and counted.
+type kafkaPublisher struct {
+	writer *kafka.Writer
+	keying string
+	site   string
+	labels map[string]string
+	queue  chan kafka.Message
+	// filter, monitoring and tempUnit make the published fields those of
+	// the tigo_module_* series.
+	filter     *moduleFilter
+	monitoring *monitoringOnly
+	tempUnit   string
+	// lastTimestamp is that of the newest row published, so that rows read
+	// again, as after a tail reset, are not published twice.
+	lastTimestamp float64
+}
+
+func newKafkaPublisher(cfg *Config, labels map[string]string, filter *moduleFilter, monitoring *monitoringOnly) (*kafkaPublisher, error) {
+	if cfg.KafkaTopic == "" {
+		return nil, fmt.Errorf("--kafka-topic is required with --kafka-brokers")
+	}
+
+	switch cfg.KafkaKey {
+	case "":
+		cfg.KafkaKey = KAFKA_KEY_SITE_MODULE
+	case KAFKA_KEY_SITE_MODULE, KAFKA_KEY_MODULE, KAFKA_KEY_NONE:
+	default:
+		return nil, fmt.Errorf("invalid --kafka-key %q: must be %s, %s or %s",
+			cfg.KafkaKey, KAFKA_KEY_SITE_MODULE, KAFKA_KEY_MODULE, KAFKA_KEY_NONE)
+	}
+	if cfg.KafkaBuffer <= 0 {
+		cfg.KafkaBuffer = DEFAULT_KAFKA_BUFFER
+	}
+
+	transport := &kafka.Transport{}
+	if cfg.KafkaTLS {
+		tlsConfig := &tls.Config{InsecureSkipVerify: cfg.KafkaTLSInsecure}
+		if cfg.KafkaTLSCA != "" {
+			pem, err := os.ReadFile(cfg.KafkaTLSCA)
+			if err != nil {
+				return nil, fmt.Errorf("reading Kafka CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in %s", cfg.KafkaTLSCA)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLS = tlsConfig
+	}
+	if cfg.KafkaSASL != "" {
+		mechanism, err := kafkaSASLMechanism(cfg.KafkaSASL, cfg.KafkaUsername, cfg.KafkaPassword)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = mechanism
+	}
+
+	var brokers []string
+	for _, b := range strings.Split(cfg.KafkaBrokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+
+	k := &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        cfg.KafkaTopic,
+			Balancer:     &kafka.Hash{},
+			Transport:    transport,
+			RequiredAcks: kafka.RequireAll,
+			MaxAttempts:  1,
+			BatchSize:    KAFKA_BATCH_SIZE,
+			BatchTimeout: time.Second,
+		},
+		keying: cfg.KafkaKey,
+		site:   labels["site"],
+		labels: labels,
+		queue:  make(chan kafka.Message, cfg.KafkaBuffer),
+
+		filter:     filter,
+		monitoring: monitoring,
+		tempUnit:   cfg.TempUnit,
+	}
+	go k.run()
+	return k, nil
+}
+
+func kafkaSASLMechanism(name, user, password string) (sasl.Mechanism, error) {
+	switch strings.ToLower(name) {
+	case "plain":
+		return plain.Mechanism{Username: user, Password: password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, user, password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, user, password)
+	}
+	return nil, fmt.Errorf("invalid --kafka-sasl %q: must be plain, scram-sha-256 or scram-sha-512", name)
+}
+
+// observe queues one message per exported module of record, unless it is no
+// newer than the last record published.
+func (k *kafkaPublisher) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil || ts <= k.lastTimestamp {
+		return
+	}
+	k.lastTimestamp = ts
+
+	for i := 1; i <= moduleCount; i++ {
+		name := moduleName(i)
+		fields := publishedValues(record, i, k.filter, k.monitoring, k.tempUnit)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := json.Marshal(kafkaMessage{
+			Timestamp: ts,
+			Site:      k.site,
+			Module:    name,
+			Labels:    k.labels,
+			Fields:    fields,
+		})
+		if err != nil {
+			continue
+		}
+
+		msg := kafka.Message{Value: value, Time: time.Unix(int64(ts), 0)}
+		switch k.keying {
+		case KAFKA_KEY_SITE_MODULE:
+			msg.Key = []byte(k.site + "/" + name)
+		case KAFKA_KEY_MODULE:
+			msg.Key = []byte(name)
+		}
+
+		select {
+		case k.queue <- msg:
+		default:
+			kafkaDroppedMessages.Inc()
+		}
+	}
+}
+
+// run drains the queue in batches, retrying failed batches with backoff.
+func (k *kafkaPublisher) run() {
+	for msg := range k.queue {
+		batch := []kafka.Message{msg}
+	fill:
+		for len(batch) < KAFKA_BATCH_SIZE {
+			select {
+			case m := <-k.queue:
+				batch = append(batch, m)
+			default:
+				break fill
+			}
+		}
+
+		backoff := time.Second
+		for attempt := 1; ; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), KAFKA_WRITE_TIMEOUT)
+			err := k.writer.WriteMessages(ctx, batch...)
+			cancel()
+			if err == nil {
+				break
+			}
+
+			kafkaDeliveryFailures.Inc()
+			if attempt == KAFKA_MAX_ATTEMPTS {
+				log.Printf("Dropping %d Kafka messages after %d attempts: %v", len(batch), attempt, err)
+				kafkaDroppedMessages.Add(float64(len(batch)))
+				break
+			}
+			time.Sleep(backoff)
+			backoff = min(backoff*2, KAFKA_RETRY_BACKOFF_MAX)
+		}
+	}
+}
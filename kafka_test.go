@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKafkaPublisherSkipsRowsReadAgain(t *testing.T) {
+	k := &kafkaPublisher{queue: make(chan kafka.Message, 100), filter: &moduleFilter{}, monitoring: newMonitoringOnly(nil, false)}
+	rows := [][]string{testRecord(1000, 2, 100), testRecord(1001, 2, 110)}
+	for _, row := range rows {
+		k.observe(row, 2)
+	}
+	if len(k.queue) != 4 {
+		t.Fatalf("queued %d messages, want 4", len(k.queue))
+	}
+
+	// The tail was reset and the file read again from the top, with one
+	// new row.
+	for _, row := range append(rows, testRecord(1002, 2, 120)) {
+		k.observe(row, 2)
+	}
+	if len(k.queue) != 6 {
+		t.Errorf("queued %d messages, want 6: only the new row's", len(k.queue))
+	}
+}
+
+func TestKafkaPublisherPublishesSeriesValues(t *testing.T) {
+	unitScale = unitScales{"power": 0.5}
+	defer func() { unitScale = nil }()
+
+	filter, err := newModuleFilter("", "A2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &kafkaPublisher{
+		queue:      make(chan kafka.Message, 100),
+		filter:     filter,
+		monitoring: newMonitoringOnly([]string{"A3"}, false),
+		tempUnit:   "f",
+	}
+	record := testRecord(1000, 3, 100)
+	k.observe(record, 3)
+
+	got := make(map[string]map[string]float64)
+	for len(k.queue) > 0 {
+		var m kafkaMessage
+		if err := json.Unmarshal((<-k.queue).Value, &m); err != nil {
+			t.Fatal(err)
+		}
+		got[m.Module] = m.Fields
+	}
+	if _, ok := got["A2"]; ok || len(got) != 2 {
+		t.Fatalf("published modules %v, want A1 and A3", got)
+	}
+	if got["A1"]["power"] != 50 {
+		t.Errorf("A1 power %v, want 50 after --unit-multiplier", got["A1"]["power"])
+	}
+	if want := convertTemp(moduleValues(record, 1)["temp"], "f"); got["A1"]["temp"] != want {
+		t.Errorf("A1 temp %v, want %v in Fahrenheit", got["A1"]["temp"], want)
+	}
+	if _, ok := got["A3"]["power"]; ok {
+		t.Error("monitoring-only A3 published power")
+	}
+	if _, ok := got["A3"]["volts"]; ok {
+		t.Error("configured monitoring-only A3 published volts")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are the label names of the exporter's own tigo_* series,
+// rejected up front: labelGatherer fails every scrape on a collision, so a
+// name missing here would only show up as an exporter serving nothing.
+var reservedLabels = map[string]bool{
+	"name":     true,
+	"cca":      true,
+	"source":   true,
+	"location": true,
+	"string":   true,
+	"serial":   true,
+	"model":    true,
+	"firmware": true,
+	"column":   true,
+	"detected": true,
+	"reason":   true,
+	"result":   true,
+	"kind":     true,
+	// Constant labels of tigo_exporter_config_info.
+	"source_type":      true,
+	"refresh_interval": true,
+	"stale_timeout":    true,
+	"expected_modules": true,
+	"lost_energy":      true,
+	"strings":          true,
+	"calibration":      true,
+	"on_demand":        true,
+	"spool_mode":       true,
+	"temp_unit":        true,
+	// Added by the exposition format to histogram buckets and summary
+	// quantiles.
+	"le":       true,
+	"quantile": true,
+}
+
+// parseStaticLabels parses --label key=value pairs.
+func parseStaticLabels(specs []string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid label %q: want key=value", spec)
+		}
+		if !labelNamePattern.MatchString(key) || strings.HasPrefix(key, "__") {
+			return nil, fmt.Errorf("invalid label name %q", key)
+		}
+		if reservedLabels[key] {
+			return nil, fmt.Errorf("label name %q is reserved", key)
+		}
+		if _, dup := labels[key]; dup {
+			return nil, fmt.Errorf("label %q given more than once", key)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// labelGatherer adds a fixed set of labels to every tigo_* series gathered
+// from the wrapped Gatherer.
+type labelGatherer struct {
+	gatherer prometheus.Gatherer
+	labels   []*dto.LabelPair
+}
+
+func newLabelGatherer(g prometheus.Gatherer, labels map[string]string) prometheus.Gatherer {
+	if len(labels) == 0 {
+		return g
+	}
+
+	lg := &labelGatherer{gatherer: g}
+	for k, v := range labels {
+		lg.labels = append(lg.labels, &dto.LabelPair{Name: proto.String(k), Value: proto.String(v)})
+	}
+	return lg
+}
+
+// Gather adds the labels to every tigo_* series. A series that already has a
+// label of one of those names keeps its own, and the collision is returned as
+// an error, failing the scrape rather than exposing the series with a label
+// other than the one asked for.
+func (lg *labelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := lg.gatherer.Gather()
+	var errs prometheus.MultiError
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, mf := range families {
+		if !strings.HasPrefix(mf.GetName(), "tigo_") {
+			continue
+		}
+		collided := make(map[string]bool)
+		for _, m := range mf.Metric {
+			own := make(map[string]bool, len(m.Label))
+			for _, l := range m.Label {
+				own[l.GetName()] = true
+			}
+			for _, l := range lg.labels {
+				if own[l.GetName()] {
+					collided[l.GetName()] = true
+					continue
+				}
+				m.Label = append(m.Label, l)
+			}
+			sort.Slice(m.Label, func(i, j int) bool {
+				return m.Label[i].GetName() < m.Label[j].GetName()
+			})
+		}
+		names := make([]string, 0, len(collided))
+		for name := range collided {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			errs = append(errs, fmt.Errorf("--label %s collides with a label of %s", name, mf.GetName()))
+		}
+	}
+	return families, errs.MaybeUnwrap()
+}
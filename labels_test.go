@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseStaticLabelsRejectsReserved(t *testing.T) {
+	for _, key := range []string{"name", "serial", "kind", "temp_unit", "le", "__meta"} {
+		if _, err := parseStaticLabels([]string{key + "=x"}); err == nil {
+			t.Errorf("no error for --label %s", key)
+		}
+	}
+}
+
+func TestLabelGathererRejectsCollision(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	info := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tigo_test_info", Help: "test"}, []string{"name", "serial"})
+	info.WithLabelValues("A1", "04C0FFEE").Set(1)
+	reg.MustRegister(info)
+
+	// parseStaticLabels rejects serial; the gatherer still guards series
+	// whose labels are not in reservedLabels.
+	labels := map[string]string{"serial": "site", "site": "roof"}
+	families, err := newLabelGatherer(reg, labels).Gather()
+	if err == nil || !strings.Contains(err.Error(), "serial") {
+		t.Fatalf("got error %v, want a collision on serial", err)
+	}
+
+	got := make(map[string]string)
+	for _, l := range families[0].Metric[0].Label {
+		if _, dup := got[l.GetName()]; dup {
+			t.Errorf("label %s appears twice", l.GetName())
+		}
+		got[l.GetName()] = l.GetValue()
+	}
+	if got["serial"] != "04C0FFEE" || got["site"] != "roof" {
+		t.Errorf("got labels %v, want the series' own serial and site=roof", got)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var moduleLostEnergy = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tigo_module_lost_energy_wh_total",
+		Help: help("tigo_module_lost_energy_wh_total", "Estimated, not measured, energy a module did not produce while offline in Wh: "+
+			"the average power per rated W of the online modules times the module's rated W, integrated over the outage"),
+	},
+	[]string{"name"},
+)
+
+func init() {
+	prometheus.MustRegister(moduleLostEnergy)
+}
+
+// parseModuleRatings parses --module-wp NAME=W overrides of the rated power
+// of single modules.
+func parseModuleRatings(specs []string) (map[string]float64, error) {
+	ratings := make(map[string]float64)
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid module rating %q: want NAME=W", spec)
+		}
+		wp, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || wp <= 0 {
+			return nil, fmt.Errorf("invalid module rating %q: W must be a positive number", spec)
+		}
+		ratings[strings.TrimSpace(name)] = wp
+	}
+	return ratings, nil
+}
+
+// lostEnergy estimates the energy of offline modules row by row. A module is
+// online in a row if it reported a power value and offline otherwise; while
+// it is offline it is assumed to have produced what the online modules did
+// per rated W. Without any ratings all modules are taken to be equal, and
+// monitoring-only modules are left out.
+type lostEnergy struct {
+	defaultWp  float64
+	ratings    map[string]float64
+	monitoring *monitoringOnly
+
+	lastTimestamp float64
+	lastEstimate  map[string]float64
+}
+
+func newLostEnergy(defaultWp float64, ratings map[string]float64, monitoring *monitoringOnly) *lostEnergy {
+	if defaultWp <= 0 {
+		defaultWp = 1
+	}
+	return &lostEnergy{defaultWp: defaultWp, ratings: ratings, monitoring: monitoring}
+}
+
+func (l *lostEnergy) rating(name string) float64 {
+	if wp, ok := l.ratings[name]; ok {
+		return wp
+	}
+	return l.defaultWp
+}
+
+// observe accounts for the interval ending at record using the estimate made
+// at its start, then updates module states and the estimate from record.
+// Rows not newer than the previous one are ignored, and intervals longer
+// than STALE_TIMEOUT are not integrated.
+func (l *lostEnergy) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil || (l.lastTimestamp != 0 && ts <= l.lastTimestamp) {
+		return
+	}
+	if l.lastTimestamp != 0 {
+		if dt := ts - l.lastTimestamp; dt <= STALE_TIMEOUT.Seconds() {
+			for name, watts := range l.lastEstimate {
+				moduleLostEnergy.WithLabelValues(name).Add(watts * dt / 3600)
+			}
+		}
+	}
+	l.lastTimestamp = ts
+
+	power, ratedOnline := 0.0, 0.0
+	var offline []string
+	for i := 1; i <= moduleCount; i++ {
+		name := moduleName(i)
+		if l.monitoring.is(name) {
+			// Never produces anything, so never loses anything either.
+			continue
+		}
+		pin, err := moduleField(record, i, PIN_OFFSET)
+		if err != nil {
+			offline = append(offline, name)
+			continue
+		}
+		moduleLostEnergy.WithLabelValues(name).Add(0)
+		power += pin
+		ratedOnline += l.rating(name)
+	}
+
+	l.lastEstimate = make(map[string]float64, len(offline))
+	if ratedOnline == 0 {
+		// Nothing to compare with, e.g. the whole array is down at night.
+		return
+	}
+	for _, name := range offline {
+		l.lastEstimate[name] = power / ratedOnline * l.rating(name)
+	}
+}
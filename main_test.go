@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// testRecord returns a record at unix time ts of modules modules, each with
+// power pin and the other columns filled in with plausible values.
+func testRecord(ts int64, modules int, pin float64) []string {
+	record := []string{time.Unix(ts, 0).UTC().Format("2006/01/02 15:04:05"), strconv.FormatInt(ts, 10), "0"}
+	for i := 0; i < modules; i++ {
+		record = append(record, "35.0", "8.2", "31", "200", "0", "0", "150", "140", fmt.Sprintf("04C0%04X", i), "30.1", "0", strconv.FormatFloat(pin, 'f', -1, 64))
+	}
+	return record
+}
+
+// testHeader returns a header row naming the columns of modules as
+// "NAME_Vin" and so on.
+func testHeader(modules ...string) []string {
+	header := []string{"DataTime", "Unix Time", "Status"}
+	for _, name := range modules {
+		for _, column := range daqsColumnNames {
+			header = append(header, name+"_"+column)
+		}
+	}
+	return header
+}
+
+// testCSV joins rows into the text of a CSV file.
+func testCSV(rows ...[]string) string {
+	var b strings.Builder
+	for _, row := range rows {
+		b.WriteString(strings.Join(row, ","))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// seriesValue returns the value of the series of gauge for module name, and
+// whether there is one.
+func seriesValue(t *testing.T, gauge *prometheus.GaugeVec, name string) (float64, bool) {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(gauge)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		for _, m := range mf.Metric {
+			for _, l := range m.Label {
+				if l.GetName() == "name" && l.GetValue() == name {
+					return m.GetGauge().GetValue(), true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+func TestUpdateGaugeDeletesSeriesAfterMaxFailCount(t *testing.T) {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tigo_test_power", Help: "test"}, []string{"name"})
+	fails := make(map[failKey]int)
+	key := failKey{"A1", "power"}
+	feed := func(record []string) {
+		value, err := getFieldValue(record[LEADING_COLUMNS+PIN_OFFSET])
+		if err != nil {
+			fails[key]++
+		} else {
+			fails[key] = 0
+		}
+		updateGauge(gauge, "power", 1, value, fails[key])
+	}
+
+	feed(testRecord(1000, 1, 250))
+	bad := testRecord(1001, 1, 0)
+	bad[LEADING_COLUMNS+PIN_OFFSET] = "n/a"
+	for i := 1; i < MAX_FAIL_COUNT; i++ {
+		feed(bad)
+		if value, ok := seriesValue(t, gauge, "A1"); !ok || value != 250 {
+			t.Fatalf("after %d failures got %v, %v; want the last good value 250", i, value, ok)
+		}
+	}
+	feed(bad)
+	if _, ok := seriesValue(t, gauge, "A1"); ok {
+		t.Fatalf("series still there after %d failures", MAX_FAIL_COUNT)
+	}
+
+	feed(testRecord(1002, 1, 260))
+	if value, ok := seriesValue(t, gauge, "A1"); !ok || value != 260 {
+		t.Errorf("got %v, %v after a good record; want 260", value, ok)
+	}
+}
+
+func TestRefreshIntervalDefault(t *testing.T) {
+	parse := func(args ...string) Config {
+		t.Helper()
+		var cfg Config
+		p, err := arg.NewParser(arg.Config{}, &cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	if cfg := parse(); cfg.RefreshInterval != 10 {
+		t.Errorf("got --refresh-interval %d by default, want 10", cfg.RefreshInterval)
+	}
+	// Left for main to reject rather than silently replaced.
+	if cfg := parse("--refresh-interval", "0"); cfg.RefreshInterval != 0 {
+		t.Errorf("got --refresh-interval %d for 0", cfg.RefreshInterval)
+	}
+}
+
+func TestUpdateGaugeFieldsIndependent(t *testing.T) {
+	power := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tigo_test_power", Help: "test"}, []string{"name"})
+	volts := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tigo_test_volts", Help: "test"}, []string{"name"})
+	fails := make(map[failKey]int)
+	feed := func(record []string) {
+		for _, f := range []struct {
+			gauge  *prometheus.GaugeVec
+			field  string
+			offset int
+		}{{power, "power", PIN_OFFSET}, {volts, "volts", VIN_OFFSET}} {
+			key := failKey{"A1", f.field}
+			value, err := getFieldValue(record[LEADING_COLUMNS+f.offset])
+			if err != nil {
+				fails[key]++
+			} else {
+				fails[key] = 0
+			}
+			updateGauge(f.gauge, f.field, 1, value, fails[key])
+		}
+	}
+
+	// Power missing from the first record the module shows up in.
+	record := testRecord(1000, 1, 0)
+	record[LEADING_COLUMNS+PIN_OFFSET] = ""
+	for i := 0; i < MAX_FAIL_COUNT; i++ {
+		feed(record)
+	}
+	if _, ok := seriesValue(t, power, "A1"); ok {
+		t.Error("power series for a module without a power reading")
+	}
+	if value, ok := seriesValue(t, volts, "A1"); !ok || value != 35 {
+		t.Errorf("got volts %v, %v; want 35 while power is missing", value, ok)
+	}
+}
+
+func TestPublishTimestampKeepsLastGood(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "tigo_test_timestamp", Help: "test"})
+	published, err := publishTimestamp(gauge, testRecord(1717243200, 1, 100), 0)
+	if err != nil || published != 1717243200 {
+		t.Fatalf("got %v, %v; want 1717243200", published, err)
+	}
+
+	blank := testRecord(1717243260, 1, 100)
+	blank[TIMESTAMP_COLUMN] = ""
+	got, err := publishTimestamp(gauge, blank, published)
+	if err == nil {
+		t.Error("no error for a blank timestamp")
+	}
+	if got != published {
+		t.Errorf("returned %v for a blank timestamp, want the last good %v", got, published)
+	}
+	m := &dto.Metric{}
+	if err := gauge.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.GetGauge().GetValue() != 1717243200 {
+		t.Errorf("gauge is %v after a blank timestamp, want 1717243200", m.GetGauge().GetValue())
+	}
+}
+
+func TestParseDAQSTrailingCommaHeader(t *testing.T) {
+	path := filepath.Join("testdata", "trailing_comma.csv")
+	for _, positional := range []bool{false, true} {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		daqs, err := parseDAQS(file, path, csvOptions{PositionalNames: positional})
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if daqs.ModuleCount != 2 {
+			t.Errorf("positional %v: got %d modules, want 2", positional, daqs.ModuleCount)
+		}
+		for i, want := range []float64{250, 260} {
+			if pin, err := moduleField(daqs.Records[0], i+1, PIN_OFFSET); err != nil || pin != want {
+				t.Errorf("positional %v: module %d has power %v, %v; want %v", positional, i+1, pin, err, want)
+			}
+		}
+	}
+}
+
+func TestParseDAQSLazyQuotes(t *testing.T) {
+	path := filepath.Join("testdata", "stray_quote.csv")
+	parse := func(opts csvOptions) *daqsFile {
+		t.Helper()
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		daqs, err := parseDAQS(file, path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return daqs
+	}
+
+	if daqs := parse(csvOptions{}); len(daqs.Records) != 1 {
+		t.Errorf("strict: got %d records, want only the one before the stray quote", len(daqs.Records))
+	}
+	daqs := parse(csvOptions{LazyQuotes: true})
+	if len(daqs.Records) != 3 {
+		t.Fatalf("lazy: got %d records, want 3", len(daqs.Records))
+	}
+	for i, h := range daqs.Headers {
+		if h == "A1_Details" && daqs.Records[1][i] != `Bypass 12" panel` {
+			t.Errorf("lazy: got Details %q, want it with the quote", daqs.Records[1][i])
+		}
+	}
+}
+
+func TestSelectRecordOutOfOrder(t *testing.T) {
+	path := filepath.Join("testdata", "out_of_order.csv")
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	daqs, err := parseDAQS(file, path, csvOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := selectRecord(daqs.Records, SELECT_LAST); got != 3 {
+		t.Errorf("last: got record %d, want 3", got)
+	}
+	got := selectRecord(daqs.Records, SELECT_MAX_TIMESTAMP)
+	if ts := daqs.Records[got][TIMESTAMP_COLUMN]; ts != "1717243380" {
+		t.Errorf("max-timestamp: got record %d at %s, want the one at 1717243380", got, ts)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	maintenanceMode = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tigo_maintenance_mode",
+			Help: help("tigo_maintenance_mode", "Whether reading is paused for maintenance"),
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(maintenanceMode)
+}
+
+// maintenance pauses the refresh loop: while enabled nothing is read, no
+// errors are logged and the staleness reset is suppressed, so the last
+// published values are held.
+type maintenance struct {
+	enabled atomic.Bool
+}
+
+func (m *maintenance) set(enabled bool) {
+	if m.enabled.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		log.Printf("Maintenance mode enabled: reading paused")
+		maintenanceMode.Set(1)
+	} else {
+		log.Printf("Maintenance mode disabled: reading resumed")
+		maintenanceMode.Set(0)
+	}
+}
+
+func (m *maintenance) active() bool {
+	return m.enabled.Load()
+}
+
+// ServeHTTP handles POST /maintenance. The enabled query parameter sets the
+// mode explicitly; without it the mode is toggled.
+func (m *maintenance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	enabled := !m.active()
+	if v := r.URL.Query().Get("enabled"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid enabled value %q", v), http.StatusBadRequest)
+			return
+		}
+		enabled = b
+	}
+
+	m.set(enabled)
+	fmt.Fprintf(w, "maintenance=%t\n", enabled)
+}
+
+// toggleOn flips maintenance mode every time a signal arrives on c.
+func (m *maintenance) toggleOn(c <-chan os.Signal) {
+	for range c {
+		m.set(!m.active())
+	}
+}
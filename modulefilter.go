@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// moduleFilter decides by name which modules are exported. Both expressions
+// are anchored at both ends, as in Prometheus relabeling; a module matching
+// exclude is dropped even if it matches include.
+type moduleFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+func newModuleFilter(include, exclude string) (*moduleFilter, error) {
+	f := &moduleFilter{}
+	var err error
+	if include != "" {
+		if f.include, err = regexp.Compile("^(?:" + include + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid --module-include: %w", err)
+		}
+	}
+	if exclude != "" {
+		if f.exclude, err = regexp.Compile("^(?:" + exclude + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid --module-exclude: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// allows reports whether the module called name is exported.
+func (f *moduleFilter) allows(name string) bool {
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+	return f.include == nil || f.include.MatchString(name)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// moduleInfo is only registered with --module-info.
+var moduleInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tigo_module_info",
+		Help: help("tigo_module_info", "Identity of a module: its serial and, where known, hardware model and firmware, always 1"),
+	},
+	[]string{"name", "serial", "model", "firmware"},
+)
+
+// moduleHardware is what the CCA configuration tells about one module.
+type moduleHardware struct {
+	Model    string `json:"model"`
+	Firmware string `json:"firmware"`
+}
+
+// moduleInfoTracker publishes tigo_module_info for every module. The serial
+// comes from the ID column of the CSV; the model and firmware from an
+// optional JSON file exported from the CCA configuration, keyed by module
+// name, e.g.
+//
+//	{"A1": {"model": "TS4-A-O", "firmware": "3.7.1"}}
+//
+// The file is read again whenever its mtime changes, so that a firmware push
+// shows up without restarting the exporter. A file that fails to load keeps
+// what was loaded before.
+type moduleInfoTracker struct {
+	path      string
+	modTime   time.Time
+	hardware  map[string]moduleHardware
+	published map[string][]string
+}
+
+func newModuleInfoTracker(path string) (*moduleInfoTracker, error) {
+	t := &moduleInfoTracker{path: path, published: make(map[string][]string)}
+	if path != "" {
+		if err := t.load(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *moduleInfoTracker) load() error {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return err
+	}
+	var hardware map[string]moduleHardware
+	if err := json.Unmarshal(data, &hardware); err != nil {
+		return fmt.Errorf("parsing module info file %s: %w", t.path, err)
+	}
+	t.hardware = hardware
+	t.modTime = info.ModTime()
+	return nil
+}
+
+// reload reads the file again if it changed since it was last read.
+func (t *moduleInfoTracker) reload() {
+	if t.path == "" {
+		return
+	}
+	info, err := os.Stat(t.path)
+	if err != nil || info.ModTime().Equal(t.modTime) {
+		return
+	}
+	if err := t.load(); err != nil {
+		log.Printf("Error reloading module info file: %v", err)
+		return
+	}
+	log.Printf("Reloaded module info file %s", t.path)
+}
+
+// update publishes the info series of module with serial, replacing the one
+// published before if anything changed.
+func (t *moduleInfoTracker) update(module, serial string) {
+	hw := t.hardware[module]
+	labels := []string{module, serial, hw.Model, hw.Firmware}
+	previous, ok := t.published[module]
+	if ok && slices.Equal(previous, labels) {
+		return
+	}
+	if ok {
+		moduleInfo.DeleteLabelValues(previous...)
+	}
+	moduleInfo.WithLabelValues(labels...).Set(1)
+	t.published[module] = labels
+}
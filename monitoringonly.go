@@ -0,0 +1,40 @@
+package main
+
+// monitoringOnly tells which modules have no power electronics, such as
+// TS4-A-S safety units that only report temperature and RSSI, so that no
+// power or volts series are made up for them. Modules are monitoring-only if
+// configured so or, with detection enabled, while their power column has not
+// held a value in any row seen since startup.
+type monitoringOnly struct {
+	configured map[string]bool
+	detect     bool
+	reporting  map[string]bool
+}
+
+func newMonitoringOnly(names []string, detect bool) *monitoringOnly {
+	m := &monitoringOnly{
+		configured: make(map[string]bool),
+		detect:     detect,
+		reporting:  make(map[string]bool),
+	}
+	for _, name := range names {
+		m.configured[name] = true
+	}
+	return m
+}
+
+// observe records which modules have reported power.
+func (m *monitoringOnly) observe(record []string, moduleCount int) {
+	if !m.detect {
+		return
+	}
+	for i := 1; i <= moduleCount; i++ {
+		if _, err := moduleField(record, i, PIN_OFFSET); err == nil {
+			m.reporting[moduleName(i)] = true
+		}
+	}
+}
+
+func (m *monitoringOnly) is(name string) bool {
+	return m.configured[name] || (m.detect && !m.reporting[name])
+}
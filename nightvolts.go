@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const DEFAULT_NIGHT_VOLTS_THRESHOLD = 0.5
+
+// The night gauges are only registered with --night-window.
+var (
+	moduleNightVolts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_module_night_volts",
+			Help: help("tigo_module_night_volts", "Module input voltage at the first reading within --night-window of the last night"),
+		},
+		[]string{"name"},
+	)
+	moduleNightFault = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_module_night_fault",
+			Help: help("tigo_module_night_fault", "Whether the night voltage of the module was below --night-volts-threshold while that of other modules was not"),
+		},
+		[]string{"name"},
+	)
+)
+
+// nightVoltsCheck samples the input voltage of every module once a night,
+// at its first reading within window. After dark a healthy module still
+// shows a small voltage; one at hard zero while others are not points at a
+// wiring or optimizer fault that daytime data hides.
+type nightVoltsCheck struct {
+	window    daylightWindow
+	location  *time.Location
+	threshold float64
+	// sampled holds the modules already sampled on night.
+	night   string
+	sampled map[string]bool
+}
+
+func newNightVoltsCheck(window daylightWindow, location *time.Location, threshold float64) *nightVoltsCheck {
+	return &nightVoltsCheck{window: window, location: location, threshold: threshold, sampled: make(map[string]bool)}
+}
+
+func (c *nightVoltsCheck) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil {
+		return
+	}
+	t := time.Unix(int64(ts), 0).In(c.location)
+	if !c.window.contains(t) {
+		return
+	}
+	if night := t.Format(time.DateOnly); night != c.night {
+		c.night = night
+		clear(c.sampled)
+	}
+
+	volts := make(map[string]float64)
+	healthy := 0
+	for i := 1; i <= moduleCount; i++ {
+		vin, err := moduleField(record, i, VIN_OFFSET)
+		if err != nil {
+			continue
+		}
+		vin = unitScale.apply("volts", vin)
+		volts[moduleName(i)] = vin
+		if vin >= c.threshold {
+			healthy++
+		}
+	}
+	for name, vin := range volts {
+		if c.sampled[name] {
+			continue
+		}
+		c.sampled[name] = true
+		moduleNightVolts.WithLabelValues(name).Set(vin)
+		fault := 0.0
+		if vin < c.threshold && healthy > 0 {
+			fault = 1
+		}
+		moduleNightFault.WithLabelValues(name).Set(fault)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ON_DEMAND_MAX_WINDOW caps how long a read in on-demand mode is reused for.
+const ON_DEMAND_MAX_WINDOW = 5 * time.Second
+
+// onDemand refreshes the metrics when they are scraped instead of on a timer.
+// Concurrent scrapes share a single refresh, and scrapes within window of the
+// last one are served what it published without touching the source.
+type onDemand struct {
+	refresh func()
+	window  time.Duration
+
+	mu      sync.Mutex
+	running chan struct{}
+	last    time.Time
+}
+
+func newOnDemand(refresh func(), interval time.Duration) *onDemand {
+	return &onDemand{refresh: refresh, window: min(interval, ON_DEMAND_MAX_WINDOW)}
+}
+
+// run refreshes unless the last refresh is recent enough, waiting for one
+// that is already in progress rather than starting another.
+func (o *onDemand) run() {
+	o.mu.Lock()
+	if !o.last.IsZero() && time.Since(o.last) < o.window {
+		o.mu.Unlock()
+		return
+	}
+	if done := o.running; done != nil {
+		o.mu.Unlock()
+		<-done
+		return
+	}
+	done := make(chan struct{})
+	o.running = done
+	o.mu.Unlock()
+
+	defer func() {
+		o.mu.Lock()
+		o.running = nil
+		o.last = time.Now()
+		o.mu.Unlock()
+		close(done)
+	}()
+	o.refresh()
+}
+
+// handler refreshes before serving next.
+func (o *onDemand) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.run()
+		next.ServeHTTP(w, r)
+	})
+}
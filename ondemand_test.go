@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingSource counts the files opened through it.
+type countingSource struct {
+	dataSource
+	opens atomic.Int32
+}
+
+func (s *countingSource) Open(path string, offset int64) (io.ReadCloser, error) {
+	s.opens.Add(1)
+	// Slow enough for the scrapes to overlap.
+	time.Sleep(50 * time.Millisecond)
+	return s.dataSource.Open(path, offset)
+}
+
+func TestOnDemandParallelScrapesShareOneRead(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "daqs.csv")
+	if err := os.WriteFile(path, []byte(testCSV(testHeader("A1"), testRecord(1000, 1, 100))), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := &countingSource{dataSource: &localSource{dataDir: dir}}
+	refresh := func() {
+		newest, err := src.Newest()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := (&csvTail{}).read(context.Background(), src, newest.Path, newest.Size, true); err != nil {
+			t.Error(err)
+		}
+	}
+	server := httptest.NewServer(newOnDemand(refresh, 10*time.Second).handler(http.NotFoundHandler()))
+	defer server.Close()
+
+	const scrapes = 8
+	var wg sync.WaitGroup
+	for i := 0; i < scrapes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if n := src.opens.Load(); n != 1 {
+		t.Errorf("%d parallel scrapes opened the file %d times, want once", scrapes, n)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexflint/go-arg"
+)
+
+type ExportConfig struct {
+	TigoDAQSDataDir string   `arg:"positional,help:DAQS data directory: default(/mnt/ffs/data/daqs)"`
+	From            string   `arg:"--from,required,help:first day to export as YYYY-MM-DD"`
+	To              string   `arg:"--to,help:last day to export as YYYY-MM-DD: default(--from)"`
+	Output          string   `arg:"--output,help:file to write to: default(stdout)"`
+	Labels          []string `arg:"--label,separate,help:static label key=value added to every series (repeatable)"`
+	TempUnit        string   `arg:"--temp-unit,help:module temperature unit c or f: default(c)"`
+	LazyQuotes      bool     `arg:"--lazy-quotes,help:tolerate stray quotes in CSV fields"`
+	PositionalNames bool     `arg:"--positional-names,help:name modules A1 A2 ... in the order of their columns as the exporter does with --positional-names"`
+}
+
+// exportFamily is one per-module gauge of the live exporter.
+type exportFamily struct {
+	name   string
+	offset int
+}
+
+var exportFamilies = []exportFamily{
+	{"tigo_module_power", PIN_OFFSET},
+	{"tigo_module_volts", VIN_OFFSET},
+	{"tigo_module_rssi", RSSI_OFFSET},
+	{"tigo_module_temp", TEMP_OFFSET},
+}
+
+// exportOpenMetrics implements the export-openmetrics subcommand: it writes
+// the records of a range of days as OpenMetrics text with timestamps, for
+// promtool tsdb create-blocks-from openmetrics. Series are named and
+// labelled like the live exporter's. A metric family must be contiguous in
+// the output, so the files are read once per family and nothing but the
+// current file is held in memory.
+func exportOpenMetrics(args []string) {
+	var cfg ExportConfig
+	p, err := arg.NewParser(arg.Config{Program: "tigo-exporter export-openmetrics"}, &cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	switch err := p.Parse(args); {
+	case err == arg.ErrHelp:
+		p.WriteHelp(os.Stdout)
+		os.Exit(0)
+	case err != nil:
+		p.Fail(err.Error())
+	}
+
+	if cfg.TigoDAQSDataDir == "" {
+		cfg.TigoDAQSDataDir = DAQS_DIR
+	}
+	if cfg.To == "" {
+		cfg.To = cfg.From
+	}
+	from, err := time.ParseInLocation("2006-01-02", cfg.From, time.Local)
+	if err != nil {
+		p.Fail(fmt.Sprintf("invalid --from: %v", err))
+	}
+	to, err := time.ParseInLocation("2006-01-02", cfg.To, time.Local)
+	if err != nil {
+		p.Fail(fmt.Sprintf("invalid --to: %v", err))
+	}
+	end := to.AddDate(0, 0, 1)
+	switch cfg.TempUnit {
+	case "":
+		cfg.TempUnit = DEFAULT_TEMP_UNIT
+	case "c", "f":
+	default:
+		p.Fail(fmt.Sprintf("invalid --temp-unit %q: must be c or f", cfg.TempUnit))
+	}
+	labels, err := parseStaticLabels(cfg.Labels)
+	if err != nil {
+		p.Fail(err.Error())
+	}
+
+	files, err := exportFiles(cfg.TigoDAQSDataDir, from, end)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing %s: %v\n", cfg.TigoDAQSDataDir, err)
+		os.Exit(1)
+	}
+
+	out := io.Writer(os.Stdout)
+	if cfg.Output != "" {
+		file, err := os.Create(cfg.Output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", cfg.Output, err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+	w := bufio.NewWriter(out)
+
+	e := &openMetricsExport{
+		w:      w,
+		files:  files,
+		opts:   csvOptions{LazyQuotes: cfg.LazyQuotes, Trim: true, PositionalNames: cfg.PositionalNames},
+		from:   float64(from.Unix()),
+		end:    float64(end.Unix()),
+		unit:   cfg.TempUnit,
+		labels: labels,
+	}
+	for _, family := range exportFamilies {
+		e.writeFamily(family)
+	}
+	e.writeTimestamps()
+	fmt.Fprintln(w, "# EOF")
+
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// exportFiles returns the CSV files under dataDir that may hold records
+// between from and end, in the order their records were written: by the
+// date embedded in their name or, without one, by mtime.
+func exportFiles(dataDir string, from, end time.Time) ([]string, error) {
+	var files []spoolFile
+	err := filepath.Walk(dataDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".csv" {
+			return nil
+		}
+		order := info.ModTime()
+		if t, ok := embeddedDate(info.Name()); ok {
+			// A file named after a day holds nothing of other days,
+			// give or take the time zone.
+			if t.Before(from.AddDate(0, 0, -1)) || t.After(end.AddDate(0, 0, 1)) {
+				return nil
+			}
+			order = t
+		} else if info.ModTime().Before(from) {
+			return nil
+		}
+		files = append(files, spoolFile{path: path, order: order})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].order.Equal(files[j].order) {
+			return files[i].order.Before(files[j].order)
+		}
+		return files[i].path < files[j].path
+	})
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type openMetricsExport struct {
+	w      *bufio.Writer
+	files  []string
+	opts   csvOptions
+	from   float64
+	end    float64
+	unit   string
+	labels map[string]string
+}
+
+// records calls fn for every record in the export range, in timestamp order
+// within the files' order. Records not newer than the previous one, as where
+// files overlap, are skipped.
+func (e *openMetricsExport) records(fn func(record []string, moduleCount int, ts float64)) {
+	last := 0.0
+	for _, path := range e.files {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", path, err)
+			continue
+		}
+		daqs, err := parseDAQS(file, path, e.opts)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", path, err)
+			continue
+		}
+
+		moduleNames = nil
+		if daqs.Layout != nil {
+			moduleNames = daqs.Layout.names
+		}
+		for _, record := range daqs.Records {
+			ts, err := recordTimestamp(record)
+			if err != nil || ts < e.from || ts >= e.end || ts <= last {
+				continue
+			}
+			last = ts
+			fn(record, daqs.ModuleCount, ts)
+		}
+	}
+}
+
+func (e *openMetricsExport) writeFamily(family exportFamily) {
+	fmt.Fprintf(e.w, "# TYPE %s gauge\n", family.name)
+	e.records(func(record []string, moduleCount int, ts float64) {
+		for i := 1; i <= moduleCount; i++ {
+			value, err := moduleField(record, i, family.offset)
+			if err != nil {
+				continue
+			}
+			if family.offset == TEMP_OFFSET {
+				value = convertTemp(value, e.unit)
+			}
+			e.writeSample(family.name, map[string]string{"name": moduleName(i)}, value, ts)
+		}
+	})
+}
+
+func (e *openMetricsExport) writeTimestamps() {
+	fmt.Fprintln(e.w, "# TYPE tigo_timestamp gauge")
+	e.records(func(record []string, moduleCount int, ts float64) {
+		e.writeSample("tigo_timestamp", map[string]string{"source": "local", "location": "cca"}, ts, ts)
+	})
+}
+
+// writeSample writes one sample with its own labels and the static ones,
+// sorted by name as the live exporter serves them.
+func (e *openMetricsExport) writeSample(name string, own map[string]string, value, ts float64) {
+	all := make(map[string]string, len(own)+len(e.labels))
+	for k, v := range e.labels {
+		all[k] = v
+	}
+	for k, v := range own {
+		all[k] = v
+	}
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + `="` + labelValueEscaper.Replace(all[k]) + `"`
+	}
+	fmt.Fprintf(e.w, "%s{%s} %s %s\n", name, strings.Join(pairs, ","),
+		strconv.FormatFloat(value, 'g', -1, 64), strconv.FormatFloat(ts, 'f', -1, 64))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const DEFAULT_IDLE_AFTER_CYCLES = 30
+
+var pollInterval = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "tigo_poll_interval_seconds",
+		Help: help("tigo_poll_interval_seconds", "Current time between refresh cycles"),
+	},
+)
+
+func init() {
+	prometheus.MustRegister(pollInterval)
+}
+
+// idleBackoff stretches the time between refresh cycles while the source has
+// no new rows, as at night: once idleAfter cycles in a row have found nothing
+// new the interval doubles with every cycle up to max, and it drops back to
+// base on the first cycle that finds data. Data that resumes is therefore
+// seen at most max late, which alerts on data age must allow for.
+type idleBackoff struct {
+	base      time.Duration
+	max       time.Duration
+	idleAfter int
+	current   time.Duration
+}
+
+func newIdleBackoff(base, max time.Duration, idleAfter int) *idleBackoff {
+	b := &idleBackoff{base: base, max: max, idleAfter: idleAfter, current: base}
+	pollInterval.Set(base.Seconds())
+	return b
+}
+
+// next returns the time to wait after a cycle, given the number of cycles
+// in a row that found no new rows.
+func (b *idleBackoff) next(idleCycles int) time.Duration {
+	switch {
+	case b.max <= b.base || idleCycles < b.idleAfter:
+		b.current = b.base
+	case b.current*2 < b.max:
+		b.current *= 2
+	default:
+		b.current = b.max
+	}
+	pollInterval.Set(b.current.Seconds())
+	return b.current
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// modulePowerDelta is only registered with --power-delta.
+var modulePowerDelta = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tigo_module_power_delta",
+		Help: help("tigo_module_power_delta", "Module power in W minus that of the previously published record"),
+	},
+	[]string{"name"},
+)
+
+// powerDelta tracks the power last published for every module to export how
+// much it changed since, which shows cloud edges and trips that the power
+// itself smooths over. A module has no delta until two records in a row had
+// a power for it.
+type powerDelta struct {
+	previous map[string]float64
+}
+
+func newPowerDelta() *powerDelta {
+	return &powerDelta{previous: make(map[string]float64)}
+}
+
+// update publishes the delta of module given its power, if ok.
+func (d *powerDelta) update(module string, power float64, ok bool) {
+	previous, had := d.previous[module]
+	if !ok {
+		delete(d.previous, module)
+		modulePowerDelta.DeleteLabelValues(module)
+		return
+	}
+	d.previous[module] = power
+	if had {
+		modulePowerDelta.WithLabelValues(module).Set(power - previous)
+	}
+}
+
+// reset forgets all powers, as when the data has gone stale.
+func (d *powerDelta) reset() {
+	clear(d.previous)
+	modulePowerDelta.Reset()
+}
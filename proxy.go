@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// newHTTPClient returns the client of an outbound HTTP integration. Requests
+// go through proxyURL if it is set and otherwise through whatever proxy
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY name. https targets are tunnelled
+// through the proxy with CONNECT, and user info in the proxy URL is sent as
+// Proxy-Authorization.
+func newHTTPClient(timeout time.Duration, flag, proxyURL string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s %q: want a proxy URL such as http://proxy:3128", flag, proxyURL)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid %s %q: scheme must be http, https or socks5", flag, u.Redacted())
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
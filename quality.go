@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// moduleQuality is only registered with --module-quality.
+var moduleQuality = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tigo_module_quality",
+		Help: help("tigo_module_quality", "How trustworthy the current data of the module is, from 0 (not at all) to 1"),
+	},
+	[]string{"name"},
+)
+
+// qualityFields are the fields a module quality score is made of, with the
+// offset of their column in the module block.
+var qualityFields = []struct {
+	key    string
+	offset int
+}{
+	{"volts", VIN_OFFSET},
+	{"temp", TEMP_OFFSET},
+	{"rssi", RSSI_OFFSET},
+	{"power", PIN_OFFSET},
+}
+
+// qualityBound is the range of sane values of a field.
+type qualityBound struct {
+	min, max float64
+}
+
+// qualityScorer rates the data of a module as the mean of three parts, each
+// from 0 to 1:
+//
+//	parse  = mean over volts, temp, rssi and power of
+//	         1 - min(fails, MAX_FAIL_COUNT)/MAX_FAIL_COUNT
+//	         where fails is the number of records in a row the field did
+//	         not parse in
+//	range  = fraction of the fields that parsed whose value is within its
+//	         bounds, 0 if none parsed
+//	signal = 1 if the RSSI parsed and is above 0, else 0
+//
+//	quality = (parse + range + signal) / 3
+//
+// Bounds apply to values as read from the CSV, with temperatures in
+// --temp-unit.
+type qualityScorer struct {
+	bounds map[string]qualityBound
+}
+
+// newQualityScorer returns a scorer with the default bounds, overridden by
+// --quality-bounds FIELD=MIN:MAX specs.
+func newQualityScorer(specs []string, tempUnit string) (*qualityScorer, error) {
+	q := &qualityScorer{bounds: map[string]qualityBound{
+		"volts": {0, 100},
+		"temp":  {convertTemp(-40, tempUnit), convertTemp(100, tempUnit)},
+		"rssi":  {0, 255},
+		"power": {0, 1000},
+	}}
+	for _, spec := range specs {
+		field, bounds, ok := strings.Cut(spec, "=")
+		field = strings.TrimSpace(field)
+		if _, known := q.bounds[field]; !ok || !known {
+			return nil, fmt.Errorf("invalid quality bounds %q: want FIELD=MIN:MAX with FIELD one of volts temp rssi power", spec)
+		}
+		low, high, ok := strings.Cut(bounds, ":")
+		lowValue, errLow := strconv.ParseFloat(strings.TrimSpace(low), 64)
+		highValue, errHigh := strconv.ParseFloat(strings.TrimSpace(high), 64)
+		if !ok || errLow != nil || errHigh != nil || lowValue > highValue {
+			return nil, fmt.Errorf("invalid quality bounds %q: MIN and MAX must be numbers with MIN <= MAX", spec)
+		}
+		q.bounds[field] = qualityBound{lowValue, highValue}
+	}
+	return q, nil
+}
+
+// score rates a module whose parsed fields are values and whose fail count
+// of each of qualityFields is fails[key].
+func (q *qualityScorer) score(values map[string]float64, fails map[string]int) float64 {
+	var parse, inRange float64
+	parsed := 0
+	for _, f := range qualityFields {
+		failed := math.Min(float64(fails[f.key]), MAX_FAIL_COUNT)
+		parse += 1 - failed/MAX_FAIL_COUNT
+
+		value, ok := values[f.key]
+		if !ok {
+			continue
+		}
+		parsed++
+		if b := q.bounds[f.key]; value >= b.min && value <= b.max {
+			inRange++
+		}
+	}
+	parse /= float64(len(qualityFields))
+	if parsed > 0 {
+		inRange /= float64(parsed)
+	}
+
+	signal := 0.0
+	if rssi, ok := values["rssi"]; ok && rssi > 0 {
+		signal = 1
+	}
+	return (parse + inRange + signal) / 3
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var moduleRaw = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tigo_module_raw",
+		Help: help("tigo_module_raw", "Raw value of a numeric DAQS module column, by header token"),
+	},
+	[]string{"name", "column"},
+)
+
+func init() {
+	prometheus.MustRegister(moduleRaw)
+}
+
+var columnTokenInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// columnToken turns the header of a column in a module block into a stable
+// label value: the part after the module name ("Vin" in "LMU_Garage_3_Vin"),
+// lower-cased and with anything but letters, digits and underscores
+// replaced. Columns without a usable header are named by their offset.
+func columnToken(header string, offset int) string {
+	if sep := strings.LastIndexAny(header, "_ ."); sep >= 0 {
+		header = header[sep+1:]
+	}
+	token := strings.Trim(columnTokenInvalid.ReplaceAllString(strings.ToLower(header), "_"), "_")
+	if token == "" {
+		return fmt.Sprintf("col%d", offset)
+	}
+	return token
+}
+
+// exportRawColumns publishes every numeric column of every module block of
+// record. Cells that do not parse are quietly left out, so a column that is
+// sometimes text only loses its series for that record.
+func exportRawColumns(headers []string, record []string, moduleCount int) {
+	for i := 0; i < moduleCount; i++ {
+		name := moduleName(i + 1)
+		for offset := 0; offset < MODULE_COLUMNS; offset++ {
+			column := LEADING_COLUMNS + i*MODULE_COLUMNS + offset
+			if column >= len(headers) || column >= len(record) {
+				break
+			}
+
+			token := columnToken(headers[column], offset)
+			value, err := getFieldValue(record[column])
+			if err != nil {
+				moduleRaw.DeleteLabelValues(name, token)
+				continue
+			}
+			moduleRaw.WithLabelValues(name, token).Set(value)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// powerReconciliationDiff is only registered when --total-power-column maps
+// a column to compare against.
+var powerReconciliationDiff = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "tigo_power_reconciliation_diff",
+		Help: help("tigo_power_reconciliation_diff", "Total power reported by the gateway minus the summed power of the modules seen in W"),
+	},
+)
+
+// columnIndex returns the index of the header named name, compared without
+// case, or -1 if there is none.
+func columnIndex(headers []string, name string) int {
+	for i, h := range headers {
+		if strings.EqualFold(h, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// reconcilePower sets the reconciliation diff from the reported total in
+// column of record. Records where that column is missing or not a number
+// leave the last value in place.
+func reconcilePower(record []string, column int, modulePower float64) {
+	if column < 0 || column >= len(record) {
+		return
+	}
+	total, err := getFieldValue(record[column])
+	if err != nil {
+		return
+	}
+	powerReconciliationDiff.Set(total - modulePower)
+}
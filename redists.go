@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DEFAULT_REDIS_BUFFER    = 10000
+	REDIS_BATCH_SIZE        = 500
+	REDIS_WRITE_TIMEOUT     = 10 * time.Second
+	REDIS_MAX_ATTEMPTS      = 5
+	REDIS_RETRY_BACKOFF_MAX = 30 * time.Second
+)
+
+var (
+	redisWriteFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tigo_redis_write_failures_total",
+			Help: help("tigo_redis_write_failures_total", "Number of failed attempts to write a batch of samples to RedisTimeSeries"),
+		},
+	)
+	redisDroppedSamples = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tigo_redis_dropped_samples_total",
+			Help: help("tigo_redis_dropped_samples_total", "Number of samples dropped because the buffer was full or retries were exhausted"),
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(redisWriteFailures)
+	prometheus.MustRegister(redisDroppedSamples)
+}
+
+// redisSample is one TS.ADD: a single field of a single module at the row's
+// timestamp.
+type redisSample struct {
+	key       string
+	timestamp int64
+	value     float64
+	labels    []any
+}
+
+// redisWriter writes every parsed row to RedisTimeSeries. Like the Kafka
+// publisher it queues samples in a bounded buffer and writes them from its
+// own goroutine, so Redis being slow or down never blocks the refresh loop.
+// Series are created by the first TS.ADD with the configured retention and
+// labels; the client reconnects on its own.
+type redisWriter struct {
+	client    *redis.Client
+	site      string
+	labels    map[string]string
+	retention int64
+	queue     chan redisSample
+	// filter, monitoring and tempUnit make the samples written those of
+	// the tigo_module_* series.
+	filter     *moduleFilter
+	monitoring *monitoringOnly
+	tempUnit   string
+	// lastTimestamp is that of the newest row written, so that rows read
+	// again, as on every poll with --full-reread, are not queued again.
+	lastTimestamp float64
+}
+
+func newRedisWriter(cfg *Config, labels map[string]string, filter *moduleFilter, monitoring *monitoringOnly) *redisWriter {
+	if cfg.RedisBuffer <= 0 {
+		cfg.RedisBuffer = DEFAULT_REDIS_BUFFER
+	}
+
+	w := &redisWriter{
+		client: redis.NewClient(&redis.Options{
+			Addr:         cfg.RedisAddr,
+			Password:     cfg.RedisPassword,
+			DB:           cfg.RedisDB,
+			DialTimeout:  REDIS_WRITE_TIMEOUT,
+			ReadTimeout:  REDIS_WRITE_TIMEOUT,
+			WriteTimeout: REDIS_WRITE_TIMEOUT,
+		}),
+		site:      labels["site"],
+		labels:    labels,
+		retention: int64(cfg.RedisRetention) * int64(time.Hour/time.Millisecond),
+		queue:     make(chan redisSample, cfg.RedisBuffer),
+
+		filter:     filter,
+		monitoring: monitoring,
+		tempUnit:   cfg.TempUnit,
+	}
+	go w.run()
+	return w
+}
+
+// key returns the series key of one field of a module, e.g.
+// tigo:home:A1:power, leaving out the site when no site label is set.
+func (w *redisWriter) key(module, field string) string {
+	parts := []string{"tigo"}
+	if w.site != "" {
+		parts = append(parts, w.site)
+	}
+	return strings.Join(append(parts, module, field), ":")
+}
+
+// seriesLabels returns the TS.ADD LABELS arguments of one series. They only
+// take effect when the series is created.
+func (w *redisWriter) seriesLabels(module, field string) []any {
+	names := make([]string, 0, len(w.labels))
+	for name := range w.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []any{"LABELS", "module", module, "field", field}
+	for _, name := range names {
+		args = append(args, name, w.labels[name])
+	}
+	return args
+}
+
+// observe queues one sample per field per exported module of record, unless
+// it is no newer than the last record written.
+func (w *redisWriter) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil || ts <= w.lastTimestamp {
+		return
+	}
+	w.lastTimestamp = ts
+
+	for i := 1; i <= moduleCount; i++ {
+		name := moduleName(i)
+		for field, value := range publishedValues(record, i, w.filter, w.monitoring, w.tempUnit) {
+			sample := redisSample{
+				key:       w.key(name, field),
+				timestamp: int64(ts * 1000),
+				value:     value,
+				labels:    w.seriesLabels(name, field),
+			}
+			select {
+			case w.queue <- sample:
+			default:
+				redisDroppedSamples.Inc()
+			}
+		}
+	}
+}
+
+// run drains the queue in pipelined batches, retrying failed batches with
+// backoff.
+func (w *redisWriter) run() {
+	for sample := range w.queue {
+		batch := []redisSample{sample}
+	fill:
+		for len(batch) < REDIS_BATCH_SIZE {
+			select {
+			case s := <-w.queue:
+				batch = append(batch, s)
+			default:
+				break fill
+			}
+		}
+
+		backoff := time.Second
+		for attempt := 1; ; attempt++ {
+			err := w.write(batch)
+			if err == nil {
+				break
+			}
+
+			redisWriteFailures.Inc()
+			if attempt == REDIS_MAX_ATTEMPTS {
+				log.Printf("Dropping %d Redis samples after %d attempts: %v", len(batch), attempt, err)
+				redisDroppedSamples.Add(float64(len(batch)))
+				break
+			}
+			time.Sleep(backoff)
+			backoff = min(backoff*2, REDIS_RETRY_BACKOFF_MAX)
+		}
+	}
+}
+
+// write sends batch as one pipeline. Duplicate timestamps, as seen when a
+// file is re-read, overwrite the stored sample instead of failing.
+func (w *redisWriter) write(batch []redisSample) error {
+	ctx, cancel := context.WithTimeout(context.Background(), REDIS_WRITE_TIMEOUT)
+	defer cancel()
+
+	pipe := w.client.Pipeline()
+	for _, s := range batch {
+		args := []any{"TS.ADD", s.key, s.timestamp, s.value,
+			"RETENTION", w.retention, "ON_DUPLICATE", "LAST"}
+		pipe.Do(ctx, append(args, s.labels...)...)
+	}
+	cmds, err := pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestRedisWriterSkipsRowsReadAgain(t *testing.T) {
+	w := &redisWriter{queue: make(chan redisSample, 100), filter: &moduleFilter{}, monitoring: newMonitoringOnly(nil, false)}
+	rows := [][]string{testRecord(1000, 2, 100), testRecord(1001, 2, 110)}
+	for _, row := range rows {
+		w.observe(row, 2)
+	}
+	queued := len(w.queue)
+
+	// --full-reread reads the whole file again on the next poll, with one
+	// new row.
+	for _, row := range append(rows, testRecord(1002, 2, 120)) {
+		w.observe(row, 2)
+	}
+	if got := len(w.queue) - queued; got != queued/2 {
+		t.Errorf("queued %d more samples, want %d: only the new row's", got, queued/2)
+	}
+}
+
+func TestRedisWriterWritesSeriesValues(t *testing.T) {
+	filter, err := newModuleFilter("", "A2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &redisWriter{
+		queue:      make(chan redisSample, 100),
+		filter:     filter,
+		monitoring: newMonitoringOnly([]string{"A3"}, false),
+	}
+	calibration = calibrations{"A1": {"power": {Scale: 2}}}
+	defer func() { calibration = nil }()
+	w.observe(testRecord(1000, 3, 100), 3)
+
+	got := make(map[string]float64)
+	for len(w.queue) > 0 {
+		s := <-w.queue
+		got[s.key] = s.value
+	}
+	if got["tigo:A1:power"] != 200 {
+		t.Errorf("tigo:A1:power %v, want 200 after calibration", got["tigo:A1:power"])
+	}
+	for _, key := range []string{"tigo:A2:power", "tigo:A3:power", "tigo:A3:volts"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("wrote %s", key)
+		}
+	}
+	if _, ok := got["tigo:A3:temp"]; !ok {
+		t.Error("no tigo:A3:temp for monitoring-only A3")
+	}
+}
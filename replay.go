@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// replayer feeds the records of one CSV file to process as if they were
+// arriving live, for demos, dashboard work and load tests. Records keep their
+// own timestamps. Without a speed it hands over one record per refresh
+// cycle. With one, the file is played back on a clock that runs speed times
+// faster than real time from its first record, and every cycle hands over
+// the records that clock has passed, so energy counters and other trackers
+// of every row see the day as they would live. With loop it starts over
+// after the last record, with every timestamp moved on by the length of the
+// file each time, so that observers which skip rows not newer than the last
+// they saw take the repeats as new days.
+type replayer struct {
+	path  string
+	daqs  *daqsFile
+	next  int
+	loop  bool
+	speed float64
+
+	// started is when playback at speed began, at the timestamp of the
+	// first record.
+	started time.Time
+	origin  float64
+
+	// span is how far the timestamps move on each time the file starts
+	// over: from its first record to one interval past its last. pass
+	// counts the times it did.
+	span float64
+	pass int
+}
+
+func newReplayer(path string, opts csvOptions, loop bool, speed float64) (*replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	daqs, err := parseDAQS(file, path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(daqs.Records) == 0 {
+		return nil, fmt.Errorf("no records in %s", path)
+	}
+	r := &replayer{path: path, daqs: daqs, loop: loop, speed: speed, span: replaySpan(daqs.Records)}
+	if speed > 0 {
+		r.origin, err = recordTimestamp(daqs.Records[0])
+		if err != nil {
+			return nil, fmt.Errorf("first record of %s: %w", path, err)
+		}
+	}
+	return r, nil
+}
+
+// step processes the records that are due and reports whether any were
+// published.
+func (r *replayer) step(process func(csvFile string, records *daqsFile) bool) bool {
+	if r.next >= len(r.daqs.Records) {
+		if !r.loop {
+			return false
+		}
+		log.Printf("Replay of %s starting over", r.path)
+		r.next = 0
+		r.started = time.Time{}
+		r.pass++
+	}
+
+	end := r.next + 1
+	if r.speed > 0 {
+		if r.started.IsZero() {
+			r.started = time.Now()
+		}
+		clock := r.origin + time.Since(r.started).Seconds()*r.speed
+		end = r.next
+		for end < len(r.daqs.Records) {
+			// Records without a timestamp go along with the ones before.
+			if ts, err := recordTimestamp(r.daqs.Records[end]); err == nil && ts > clock {
+				break
+			}
+			end++
+		}
+		if end == r.next {
+			return false
+		}
+	}
+
+	batch := *r.daqs
+	batch.Records = r.daqs.Records[r.next:end]
+	batch.FirstRow = r.next + 1
+	if r.pass > 0 {
+		batch.Records = shiftTimestamps(batch.Records, float64(r.pass)*r.span)
+	}
+	r.next = end
+	return process(r.path, &batch)
+}
+
+// replaySpan returns the time from the first of records to one average
+// interval between them past the last, or a second if they do not tell.
+func replaySpan(records [][]string) float64 {
+	var first, last float64
+	n := 0
+	for _, record := range records {
+		ts, err := recordTimestamp(record)
+		if err != nil {
+			continue
+		}
+		if n == 0 || ts < first {
+			first = ts
+		}
+		if n == 0 || ts > last {
+			last = ts
+		}
+		n++
+	}
+	if n < 2 || last <= first {
+		return 1
+	}
+	return (last - first) * float64(n) / float64(n-1)
+}
+
+// shiftTimestamps returns copies of records with their timestamp moved on by
+// offset seconds. Records without a timestamp are left as they are.
+func shiftTimestamps(records [][]string, offset float64) [][]string {
+	shifted := make([][]string, len(records))
+	for i, record := range records {
+		shifted[i] = record
+		ts, err := recordTimestamp(record)
+		if err != nil {
+			continue
+		}
+		shifted[i] = append([]string(nil), record...)
+		shifted[i][TIMESTAMP_COLUMN] = strconv.FormatFloat(ts+offset, 'f', -1, 64)
+	}
+	return shifted
+}
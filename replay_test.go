@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplayLoopMovesTimestampsOn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "day.csv")
+	data := testCSV(testHeader("A1"), testRecord(1000, 1, 100), testRecord(1060, 1, 110), testRecord(1120, 1, 120))
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newReplayer(path, csvOptions{}, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []float64
+	for i := 0; i < 7; i++ {
+		r.step(func(csvFile string, daqs *daqsFile) bool {
+			for _, record := range daqs.Records {
+				ts, err := recordTimestamp(record)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, ts)
+			}
+			return true
+		})
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] != got[i-1]+60 {
+			t.Fatalf("got timestamps %v, want every replayed row 60s after the one before", got)
+		}
+	}
+	if len(got) != 7 {
+		t.Errorf("got %d rows, want 7", len(got))
+	}
+}
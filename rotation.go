@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var digitRun = regexp.MustCompile(`[0-9]+`)
+
+// rotationPattern matches the names of all files in the same rotation
+// sequence as name: the name with every run of digits generalized, so that
+// daqs_07.csv matches daqs_08.csv and daqs_2024-05-01.csv matches
+// daqs_2024-05-02.csv.
+func rotationPattern(name string) *regexp.Regexp {
+	parts := digitRun.Split(name, -1)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "[0-9]+") + "$")
+}
+
+// previousInRotation returns the file that preceded path in its rotation
+// sequence: the most recently modified other file in the same directory
+// whose name follows the same pattern. The Path is empty if there is none.
+func previousInRotation(path string) (fileStat, error) {
+	dir, name := filepath.Split(path)
+	if !digitRun.MatchString(name) {
+		return fileStat{}, nil
+	}
+	pattern := rotationPattern(name)
+
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return fileStat{}, err
+	}
+
+	var previous fileStat
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == name || !pattern.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(previous.ModTime) {
+			previous = fileStat{Path: filepath.Join(dir, e.Name()), ModTime: info.ModTime(), Size: info.Size()}
+		}
+	}
+	return previous, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DEFAULT_RSSI_BUCKETS covers the 0-255 scale most CCA firmware reports RSSI on.
+	DEFAULT_RSSI_BUCKETS = "25,50,75,100,125,150,175,200,225,255"
+	// DEFAULT_POWER_BUCKETS covers the output of common residential modules.
+	DEFAULT_POWER_BUCKETS = "5,25,50,100,150,200,250,300,350,400,450,500"
+)
+
+// parseBuckets parses a comma separated list of strictly increasing bucket
+// upper bounds.
+func parseBuckets(spec string) ([]float64, error) {
+	var buckets []float64
+	for _, field := range strings.Split(spec, ",") {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q", field)
+		}
+		if len(buckets) > 0 && bound <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets must be strictly increasing: %s", spec)
+		}
+		buckets = append(buckets, bound)
+	}
+	return buckets, nil
+}
+
+// NATIVE_HISTOGRAM_FACTOR bounds the growth from one native histogram bucket
+// to the next, here to 10%.
+const NATIVE_HISTOGRAM_FACTOR = 1.1
+
+// distribution observes one field of every module in every row into one
+// histogram, a compact view of the whole array for long-term retention.
+// With native it is also a native histogram, which a Prometheus that scrapes
+// those keeps instead of the classic buckets.
+type distribution struct {
+	histogram     prometheus.Histogram
+	field         string
+	offset        int
+	lastTimestamp float64
+}
+
+func newDistribution(name, text, field string, offset int, buckets []float64, native bool) *distribution {
+	opts := prometheus.HistogramOpts{
+		Name:    name,
+		Help:    help(name, text),
+		Buckets: buckets,
+	}
+	if native {
+		opts.NativeHistogramBucketFactor = NATIVE_HISTOGRAM_FACTOR
+		opts.NativeHistogramZeroThreshold = prometheus.DefNativeHistogramZeroThreshold
+	}
+	h := prometheus.NewHistogram(opts)
+	prometheus.MustRegister(h)
+	return &distribution{histogram: h, field: field, offset: offset}
+}
+
+func newRSSIDistribution(buckets []float64, native bool) *distribution {
+	return newDistribution("tigo_module_rssi_distribution",
+		"Distribution of module RSSI across the array, observed once per module per row",
+		"rssi", RSSI_OFFSET, buckets, native)
+}
+
+func newPowerDistribution(buckets []float64, native bool) *distribution {
+	return newDistribution("tigo_module_power_distribution",
+		"Distribution of module power in W across the array, observed once per module per row",
+		"power", PIN_OFFSET, buckets, native)
+}
+
+// observe adds the field of every module of record. Rows not newer than the
+// previous one are ignored so re-read rows are not counted twice.
+func (d *distribution) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil || ts <= d.lastTimestamp {
+		return
+	}
+	d.lastTimestamp = ts
+
+	for i := 1; i <= moduleCount; i++ {
+		value, err := moduleField(record, i, d.offset)
+		if err != nil {
+			continue
+		}
+		d.histogram.Observe(unitScale.apply(d.field, value))
+	}
+}
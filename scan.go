@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// scanCSVFiles ranks the CSV files under dataDir like rankCSVFiles but lists
+// up to workers directories at once, which pays off on deep archive trees.
+// The files found are put in walk order before ranking, so the result does
+// not depend on which directory happened to be listed first.
+func scanCSVFiles(dataDir string, limits *fileLimits, workers int) ([]fileStat, error) {
+	var (
+		mu       sync.Mutex
+		found    []fileStat
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	// queue holds the directories still to be listed; pending counts those
+	// and the ones being listed, so that workers stop once it is 0.
+	queue := []string{dataDir}
+	pending := 1
+	ready := sync.NewCond(&mu)
+
+	worker := func() {
+		defer wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		for {
+			for len(queue) == 0 && pending > 0 {
+				ready.Wait()
+			}
+			if pending == 0 {
+				return
+			}
+			dir := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+
+			mu.Unlock()
+			files, subdirs, err := scanDir(dir)
+			mu.Lock()
+
+			found = append(found, files...)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if firstErr != nil {
+				// Nothing more is listed once one directory failed.
+				pending -= len(queue)
+				queue, subdirs = nil, nil
+			}
+			queue = append(queue, subdirs...)
+			pending += len(subdirs) - 1
+			ready.Broadcast()
+		}
+	}
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go worker()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return walkOrderLess(found[i].Path, found[j].Path)
+	})
+	return rankCandidates(dataDir, found, limits), nil
+}
+
+// walkOrderLess reports whether filepath.Walk visits path a before path b.
+// Walk lists each directory by name and enters a subdirectory before going
+// on to the next name, so paths compare by their first differing element:
+// "d/x.csv" comes before "d-1.csv", although '-' sorts before '/'.
+func walkOrderLess(a, b string) bool {
+	for {
+		aHead, aRest, aMore := strings.Cut(a, string(filepath.Separator))
+		bHead, bRest, bMore := strings.Cut(b, string(filepath.Separator))
+		if aHead != bHead || !aMore || !bMore {
+			if aHead == bHead {
+				// One is a directory above the other, which Walk
+				// visits first.
+				return !aMore && bMore
+			}
+			return aHead < bHead
+		}
+		a, b = aRest, bRest
+	}
+}
+
+// scanDir lists the CSV files and the subdirectories of dir. Files removed
+// while it is being listed are left out.
+func scanDir(dir string) ([]fileStat, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []fileStat
+	var subdirs []string
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if e.IsDir() {
+			subdirs = append(subdirs, path)
+			continue
+		}
+		if filepath.Ext(e.Name()) != ".csv" {
+			continue
+		}
+		info, err := e.Info()
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, fileStat{Path: path, ModTime: info.ModTime(), Size: info.Size()})
+	}
+	return files, subdirs, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// makeTree creates a tree depth directories deep with fanout subdirectories
+// and files CSV files in each. Files share a handful of mtimes, so that
+// several tie for the newest.
+func makeTree(tb testing.TB, root string, depth, fanout, files int) {
+	tb.Helper()
+	base := time.Now().Add(-time.Hour)
+	n := 0
+	var fill func(dir string, level int)
+	fill = func(dir string, level int) {
+		for i := 0; i < files; i++ {
+			path := filepath.Join(dir, fmt.Sprintf("daqs_%02d.csv", i))
+			if err := os.WriteFile(path, []byte("DataTime\n"), 0644); err != nil {
+				tb.Fatal(err)
+			}
+			mtime := base.Add(time.Duration(n%5) * time.Minute)
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				tb.Fatal(err)
+			}
+			n++
+		}
+		if level == depth {
+			return
+		}
+		for i := 0; i < fanout; i++ {
+			sub := filepath.Join(dir, fmt.Sprintf("d%d", i))
+			if err := os.Mkdir(sub, 0755); err != nil {
+				tb.Fatal(err)
+			}
+			fill(sub, level+1)
+		}
+	}
+	fill(root, 0)
+}
+
+func TestScanCSVFilesDeterministic(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, 3, 3, 4)
+
+	want, err := rankCSVFiles(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 2, 4, 16} {
+		for run := 0; run < 5; run++ {
+			got, err := scanCSVFiles(root, nil, workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%d workers, run %d: ranking differs from rankCSVFiles; newest %s, want %s",
+					workers, run, got[0].Path, want[0].Path)
+			}
+		}
+	}
+}
+
+func TestScanCSVFilesBreaksTiesInWalkOrder(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Walk visits d/x.csv before d-1.csv, although "d-1.csv" sorts before
+	// "d/x.csv" byte by byte.
+	mtime := time.Now().Add(-time.Hour)
+	for _, name := range []string{"d-1.csv", filepath.Join("d", "x.csv")} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte("DataTime\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := rankCSVFiles(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 4} {
+		got, err := scanCSVFiles(root, nil, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers: got %v, want %v as rankCSVFiles", workers, got, want)
+		}
+	}
+}
+
+func TestScanCSVFilesMissingDir(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		if _, err := scanCSVFiles(filepath.Join(t.TempDir(), "missing"), nil, workers); err == nil {
+			t.Errorf("%d workers: no error for a missing data directory", workers)
+		}
+	}
+}
+
+func BenchmarkRankCSVFiles(b *testing.B) {
+	root := b.TempDir()
+	makeTree(b, root, 4, 4, 3)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rankCSVFiles(root, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanCSVFiles(b *testing.B) {
+	root := b.TempDir()
+	makeTree(b, root, 4, 4, 3)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scanCSVFiles(root, nil, 8); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
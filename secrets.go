@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SECRETS_DIR is where Docker and Compose mount secrets; a *-file flag given
+// a bare name reads it from there.
+const SECRETS_DIR = "/run/secrets"
+
+// readSecret returns the content of path without its trailing newline.
+func readSecret(path string) (string, error) {
+	if !strings.ContainsRune(path, filepath.Separator) {
+		path = filepath.Join(SECRETS_DIR, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveSecrets fills the secrets of cfg from their *-file flags, so they
+// need not appear on the command line. Giving a secret both ways is an
+// error. The source password goes into the userinfo of the source URL.
+func resolveSecrets(cfg *Config) error {
+	secrets := []struct {
+		flag  string
+		value *string
+		file  string
+	}{
+		{"--kafka-password", &cfg.KafkaPassword, cfg.KafkaPasswordFile},
+		{"--redis-password", &cfg.RedisPassword, cfg.RedisPasswordFile},
+		{"--emoncms-apikey", &cfg.EmoncmsAPIKey, cfg.EmoncmsAPIKeyFile},
+	}
+	for _, s := range secrets {
+		if s.file == "" {
+			continue
+		}
+		if *s.value != "" {
+			return fmt.Errorf("%s and %s-file are mutually exclusive", s.flag, s.flag)
+		}
+		value, err := readSecret(s.file)
+		if err != nil {
+			return fmt.Errorf("%s-file: %w", s.flag, err)
+		}
+		*s.value = value
+	}
+
+	if cfg.SourcePasswordFile == "" {
+		return nil
+	}
+	u, err := url.Parse(cfg.TigoDAQSDataDir)
+	if err != nil || (u.Scheme != "ftp" && u.Scheme != "smb") {
+		return fmt.Errorf("--source-password-file requires an ftp:// or smb:// source")
+	}
+	if _, ok := u.User.Password(); ok {
+		return fmt.Errorf("--source-password-file and a password in the source URL are mutually exclusive")
+	}
+	password, err := readSecret(cfg.SourcePasswordFile)
+	if err != nil {
+		return fmt.Errorf("--source-password-file: %w", err)
+	}
+	user := u.User.Username()
+	if user == "" {
+		return fmt.Errorf("--source-password-file requires a user name in the source URL")
+	}
+	u.User = url.UserPassword(user, password)
+	cfg.TigoDAQSDataDir = u.String()
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// traceFileSelection is set by --trace-file-selection and makes the newest
+// file searches log every candidate and the outcome.
+var traceFileSelection bool
+
+// traceCandidate logs a candidate CSV file and what the search made of it.
+func traceCandidate(path string, modTime time.Time, size int64, verdict string) {
+	if traceFileSelection {
+		log.Printf("Candidate %s: mtime %s size %d: %s", path, modTime.Format(time.RFC3339Nano), size, verdict)
+	}
+}
+
+// traceSelected logs the file a search of dataDir settled on.
+func traceSelected(dataDir, path string) {
+	if !traceFileSelection {
+		return
+	}
+	if path == "" {
+		log.Printf("Selected no CSV file in %s", dataDir)
+		return
+	}
+	log.Printf("Selected %s in %s", path, dataDir)
+}
+
+// selectionVerdict describes a candidate for traceCandidate.
+func selectionVerdict(newer, accepted bool) string {
+	switch {
+	case !newer:
+		return "not newer"
+	case !accepted:
+		return "rejected by limits"
+	}
+	return "newest so far"
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// startService fails: --service is only supported on Windows.
+func startService(action string) (done bool, err error) {
+	return false, fmt.Errorf("--service is only supported on Windows")
+}
+
+// serviceExited is a no-op outside Windows.
+func serviceExited() {}
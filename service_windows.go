@@ -0,0 +1,194 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const SERVICE_NAME = "tigo-exporter"
+
+var (
+	// serviceStop carries a stop or shutdown from the service control
+	// manager to the shutdown channel of main.
+	serviceStop = make(chan os.Signal, 1)
+	// serviceMainDone is closed once main has shut down, serviceDone once
+	// the control manager has been told the service stopped.
+	serviceMainDone = make(chan struct{})
+	serviceDone     chan struct{}
+)
+
+// startService carries out --service: install and uninstall register and
+// remove the service and report done; run connects to the service control
+// manager and sends log output to the event log, leaving main to carry on
+// as the service.
+func startService(action string) (done bool, err error) {
+	switch action {
+	case "install":
+		return true, installService()
+	case "uninstall":
+		return true, uninstallService()
+	case "run":
+		return false, runService()
+	}
+	return false, fmt.Errorf("invalid --service %q: must be install, uninstall or run", action)
+}
+
+// installService registers the service to start automatically, running this
+// executable with the arguments it was given apart from --service.
+func installService() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.Abs(exe)
+	if err != nil {
+		return err
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	args := append([]string{"--service", "run"}, withoutServiceFlag(os.Args[1:])...)
+	s, err := m.CreateService(SERVICE_NAME, exe, mgr.Config{
+		DisplayName: "Tigo exporter",
+		Description: "Prometheus exporter for Tigo CCA module data",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("creating service %s: %w", SERVICE_NAME, err)
+	}
+	defer s.Close()
+
+	if err := eventlog.InstallAsEventCreate(SERVICE_NAME, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("registering event log source: %w", err)
+	}
+	fmt.Printf("Installed service %s\n", SERVICE_NAME)
+	return nil
+}
+
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(SERVICE_NAME)
+	if err != nil {
+		return fmt.Errorf("opening service %s: %w", SERVICE_NAME, err)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("deleting service %s: %w", SERVICE_NAME, err)
+	}
+	if err := eventlog.Remove(SERVICE_NAME); err != nil {
+		log.Printf("Error removing event log source: %v", err)
+	}
+	fmt.Printf("Uninstalled service %s\n", SERVICE_NAME)
+	return nil
+}
+
+func runService() error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return fmt.Errorf("--service run must be started by the service manager")
+	}
+
+	elog, err := eventlog.Open(SERVICE_NAME)
+	if err != nil {
+		return fmt.Errorf("opening event log: %w", err)
+	}
+	log.SetFlags(0)
+	log.SetOutput(eventLogWriter{elog})
+
+	serviceDone = make(chan struct{})
+	go func() {
+		defer close(serviceDone)
+		if err := svc.Run(SERVICE_NAME, exporterService{}); err != nil {
+			elog.Error(1, fmt.Sprintf("Service failed: %v", err))
+			os.Exit(1)
+		}
+	}()
+	return nil
+}
+
+// serviceExited is called when main has shut down and, when running as a
+// service, waits for the control manager to be told.
+func serviceExited() {
+	if serviceDone == nil {
+		return
+	}
+	close(serviceMainDone)
+	<-serviceDone
+}
+
+// exporterService answers the service control manager. A stop or a system
+// shutdown goes through the same graceful shutdown as Ctrl-C.
+type exporterService struct{}
+
+func (exporterService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				select {
+				case serviceStop <- os.Interrupt:
+				default:
+				}
+				<-serviceMainDone
+				return false, 0
+			}
+		case <-serviceMainDone:
+			// Main gave up on its own, e.g. the port was taken.
+			return false, 1
+		}
+	}
+}
+
+// eventLogWriter sends log output to the Windows event log.
+type eventLogWriter struct {
+	elog *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	if err := w.elog.Info(1, strings.TrimRight(string(p), "\n")); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// withoutServiceFlag returns args without --service and its value.
+func withoutServiceFlag(args []string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--service":
+			i++
+		case strings.HasPrefix(args[i], "--service="):
+		default:
+			kept = append(kept, args[i])
+		}
+	}
+	return kept
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DEFAULT_DROP_THRESHOLD_PCT = 30
+	DEFAULT_DROP_MEDIAN_PCT    = 10
+	DEFAULT_DROP_WINDOW        = 10
+	DROP_MIN_POWER_W           = 10
+)
+
+var (
+	modulePowerDropEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tigo_module_power_drop_events_total",
+			Help: help("tigo_module_power_drop_events_total", "Number of times module power dropped below its trailing average while the array median stayed flat"),
+		},
+		[]string{"name"},
+	)
+	modulePowerDropSeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tigo_module_power_drop_seconds_total",
+			Help: help("tigo_module_power_drop_seconds_total", "Seconds spent in a power drop event"),
+		},
+		[]string{"name"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(modulePowerDropEvents)
+	prometheus.MustRegister(modulePowerDropSeconds)
+}
+
+// trailingAverage is the mean of the last len(values) observations.
+type trailingAverage struct {
+	values []float64
+	next   int
+	count  int
+	sum    float64
+}
+
+func newTrailingAverage(window int) *trailingAverage {
+	return &trailingAverage{values: make([]float64, window)}
+}
+
+func (a *trailingAverage) add(v float64) {
+	if a.count == len(a.values) {
+		a.sum -= a.values[a.next]
+	} else {
+		a.count++
+	}
+	a.values[a.next] = v
+	a.sum += v
+	a.next = (a.next + 1) % len(a.values)
+}
+
+// full reports whether the window has been filled since the last reset.
+func (a *trailingAverage) full() bool {
+	return a.count == len(a.values)
+}
+
+func (a *trailingAverage) mean() float64 {
+	if a.count == 0 {
+		return 0
+	}
+	return a.sum / float64(a.count)
+}
+
+type moduleDropState struct {
+	average *trailingAverage
+	dropped bool
+}
+
+// dropDetector counts transient per-module power drops that the rest of the
+// array does not share, a signature of partial shading. Its state is reset
+// at midnight in location.
+type dropDetector struct {
+	threshold       float64
+	medianTolerance float64
+	window          int
+	location        *time.Location
+
+	day           string
+	lastTimestamp float64
+	median        *trailingAverage
+	modules       map[string]*moduleDropState
+}
+
+func newDropDetector(thresholdPct, medianTolerancePct float64, window int, location *time.Location) *dropDetector {
+	d := &dropDetector{
+		threshold:       thresholdPct / 100,
+		medianTolerance: medianTolerancePct / 100,
+		window:          window,
+		location:        location,
+	}
+	d.reset()
+	return d
+}
+
+// reset forgets all trailing state; detection resumes once the windows have
+// refilled, so a restart, a new day or a data gap never fires spurious events.
+func (d *dropDetector) reset() {
+	d.lastTimestamp = 0
+	d.median = newTrailingAverage(d.window)
+	d.modules = make(map[string]*moduleDropState)
+}
+
+// observe runs detection over one record. Rows not newer than the previous
+// one are ignored.
+func (d *dropDetector) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil || (d.lastTimestamp != 0 && ts <= d.lastTimestamp) {
+		return
+	}
+
+	day := time.Unix(int64(ts), 0).In(d.location).Format("2006-01-02")
+	if day != d.day || (d.lastTimestamp != 0 && ts-d.lastTimestamp > STALE_TIMEOUT.Seconds()) {
+		d.day = day
+		d.reset()
+	}
+	dt := ts - d.lastTimestamp
+	if d.lastTimestamp == 0 {
+		dt = 0
+	}
+	d.lastTimestamp = ts
+
+	powers := make(map[string]float64, moduleCount)
+	values := make([]float64, 0, moduleCount)
+	for i := 1; i <= moduleCount; i++ {
+		power, err := moduleField(record, i, PIN_OFFSET)
+		if err != nil {
+			continue
+		}
+		powers[moduleName(i)] = power
+		values = append(values, power)
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	median := medianOf(values)
+	medianFlat := d.median.full() && math.Abs(median-d.median.mean()) <= d.medianTolerance*d.median.mean()
+	d.median.add(median)
+
+	for name, power := range powers {
+		st, ok := d.modules[name]
+		if !ok {
+			st = &moduleDropState{average: newTrailingAverage(d.window)}
+			d.modules[name] = st
+			modulePowerDropEvents.WithLabelValues(name).Add(0)
+			modulePowerDropSeconds.WithLabelValues(name).Add(0)
+		}
+
+		avg := st.average.mean()
+		below := st.average.full() && avg >= DROP_MIN_POWER_W && power < avg*(1-d.threshold)
+
+		switch {
+		case st.dropped && below:
+			modulePowerDropSeconds.WithLabelValues(name).Add(dt)
+		case st.dropped:
+			modulePowerDropSeconds.WithLabelValues(name).Add(dt)
+			st.dropped = false
+		case below && medianFlat:
+			modulePowerDropEvents.WithLabelValues(name).Inc()
+			st.dropped = true
+		}
+
+		// Samples taken during a drop would drag the baseline down and end
+		// the event early, so they are kept out of the trailing average.
+		if !st.dropped {
+			st.average.add(power)
+		}
+	}
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
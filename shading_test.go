@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDropDetectorDayInLocation(t *testing.T) {
+	pacific := time.FixedZone("PDT", -7*3600)
+	d := newDropDetector(DEFAULT_DROP_THRESHOLD_PCT, DEFAULT_DROP_MEDIAN_PCT, DEFAULT_DROP_WINDOW, pacific)
+
+	// 03:00 UTC is still the evening before at the site.
+	ts := time.Date(2024, 6, 2, 3, 0, 0, 0, time.UTC).Unix()
+	d.observe(testRecord(ts, 2, 100), 2)
+	if d.day != "2024-06-01" {
+		t.Errorf("got day %s, want 2024-06-01 in --timezone", d.day)
+	}
+}
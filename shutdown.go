@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DEFAULT_SHUTDOWN_TIMEOUT_SEC = 5
+
+// connTracker keeps the remote addresses of the server's connections that
+// are in the middle of a request, to report which ones a shutdown cut off.
+type connTracker struct {
+	mu     sync.Mutex
+	active map[net.Conn]bool
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{active: make(map[net.Conn]bool)}
+}
+
+// track is installed as the server's ConnState hook.
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state == http.StateActive {
+		t.active[c] = true
+	} else {
+		delete(t.active, c)
+	}
+}
+
+func (t *connTracker) activeAddrs() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	addrs := make([]string, 0, len(t.active))
+	for c := range t.active {
+		addrs = append(addrs, c.RemoteAddr().String())
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// shutdownServer stops server from accepting connections and waits up to
+// timeout for in-flight requests to finish before closing what is left.
+func shutdownServer(server *http.Server, conns *connTracker, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err == nil {
+		return
+	}
+	log.Printf("Shutdown did not finish within %s: %v", timeout, err)
+	for _, addr := range conns.activeAddrs() {
+		log.Printf("Dropping connection from %s", addr)
+	}
+	server.Close()
+}
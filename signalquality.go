@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	moduleSNR = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_module_snr",
+			Help: help("tigo_module_snr", "Tigo signal to noise ratio value"),
+		},
+		[]string{"name"},
+	)
+	moduleNoise = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_module_noise",
+			Help: help("tigo_module_noise", "Tigo noise floor value"),
+		},
+		[]string{"name"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(moduleSNR)
+	prometheus.MustRegister(moduleNoise)
+}
+
+// qualityColumn is the signal quality column of one module. Depending on
+// firmware the CCA logs either an SNR or a noise floor next to the RSSI.
+type qualityColumn struct {
+	index int
+	field string
+	gauge *prometheus.GaugeVec
+}
+
+// findQualityColumns looks up the signal quality column of every module by
+// header name: a module whose Vin column is "LMU_Garage_3_Vin" has its SNR
+// in "LMU_Garage_3_SNR" or its noise floor in "LMU_Garage_3_Noise", wherever
+// that column is. Modules without one are left out.
+func findQualityColumns(headers []string, moduleCount int) map[int]qualityColumn {
+	byName := make(map[string]int, len(headers))
+	for i, h := range headers {
+		byName[strings.ToLower(h)] = i
+	}
+
+	columns := make(map[int]qualityColumn)
+	for moduleIndex := 1; moduleIndex <= moduleCount; moduleIndex++ {
+		vinColumn := LEADING_COLUMNS + (moduleIndex-1)*MODULE_COLUMNS + VIN_OFFSET
+		if vinColumn >= len(headers) {
+			break
+		}
+		vin := strings.ToLower(headers[vinColumn])
+		if !strings.HasSuffix(vin, "vin") {
+			continue
+		}
+		prefix := strings.TrimSuffix(vin, "vin")
+
+		if i, ok := byName[prefix+"snr"]; ok {
+			columns[moduleIndex] = qualityColumn{index: i, field: "snr", gauge: moduleSNR}
+		} else if i, ok := byName[prefix+"noise"]; ok {
+			columns[moduleIndex] = qualityColumn{index: i, field: "noise", gauge: moduleNoise}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySummarySignal delivers SIGUSR1 to c.
+func notifySummarySignal(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
+
+// notifyShutdownSignal delivers SIGINT and SIGTERM to c.
+func notifyShutdownSignal(c chan<- os.Signal) {
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+}
+
+// notifyMaintenanceSignal delivers SIGUSR2 to c.
+func notifyMaintenanceSignal(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR2)
+}
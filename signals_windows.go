@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+)
+
+// notifySummarySignal is a no-op: Windows has no SIGUSR1.
+func notifySummarySignal(c chan<- os.Signal) {}
+
+// notifyShutdownSignal delivers Ctrl-C and, when running as a service, a
+// stop from the service control manager to c.
+func notifyShutdownSignal(c chan<- os.Signal) {
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		c <- <-serviceStop
+	}()
+}
+
+// notifyMaintenanceSignal is a no-op: Windows has no SIGUSR2.
+func notifyMaintenanceSignal(c chan<- os.Signal) {}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// moduleAlias returns the name the CCA logs the module at startIndex under,
+// such as "LMU_Garage_3" for the "LMU_Garage_3_Vin" column.
+func moduleAlias(headers []string, startIndex int) string {
+	if startIndex+VIN_OFFSET >= len(headers) {
+		return ""
+	}
+	header := headers[startIndex+VIN_OFFSET]
+	for _, suffix := range []string{"_vin2", "_vin"} {
+		if strings.HasSuffix(strings.ToLower(header), suffix) {
+			return header[:len(header)-len(suffix)]
+		}
+	}
+	return header
+}
+
+// snapshotCSVHandler serves the published snapshot as a spreadsheet of one
+// row per module. groups supplies the string column.
+func snapshotCSVHandler(mu *sync.Mutex, current *snapshot, groups []stringGroup) http.Handler {
+	stringOf := make(map[string]string)
+	for _, g := range groups {
+		for _, module := range g.Modules {
+			stringOf[module] = g.Name
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		s := *current
+		mu.Unlock()
+
+		if s.File == "" {
+			http.Error(w, "no data read yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		dataTime := time.Unix(int64(s.Timestamp), 0).UTC()
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="tigo-snapshot-%s.csv"`, dataTime.Format("20060102T150405Z")))
+
+		out := csv.NewWriter(w)
+		out.Write([]string{"name", "alias", "power", "volts", "temp", "rssi", "last_seen", "string"})
+		for _, m := range s.Modules {
+			row := []string{m.Name, m.Alias}
+			for _, field := range []string{"power", "volts", "temp", "rssi"} {
+				if v, ok := m.Values[field]; ok {
+					row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+				} else {
+					row = append(row, "")
+				}
+			}
+			lastSeen := ""
+			if m.LastSeen > 0 {
+				lastSeen = time.Unix(int64(m.LastSeen), 0).UTC().Format(time.RFC3339)
+			}
+			row = append(row, lastSeen, stringOf[m.Name])
+			out.Write(row)
+		}
+		out.Flush()
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// fileStat describes a candidate CSV file in a data source.
+type fileStat struct {
+	Path    string
+	ModTime time.Time
+	Size    int64
+}
+
+// dataSource is somewhere DAQS CSV files can be read from.
+type dataSource interface {
+	// Newest returns the newest CSV file in the source.
+	Newest() (fileStat, error)
+	// Open returns the content of path starting at byte offset.
+	Open(path string, offset int64) (io.ReadCloser, error)
+}
+
+// rankedSource is a dataSource that remembers the other candidates of its
+// last Newest, so a file removed between being picked and being read can be
+// replaced by the next newest one within the same cycle.
+type rankedSource interface {
+	dataSource
+	// nextNewest returns the newest remaining candidate older than path,
+	// or errNoOlderFile.
+	nextNewest(path string) (fileStat, error)
+}
+
+// remoteSource is a dataSource fetched over the network, probed for
+// reachability on every cycle.
+type remoteSource interface {
+	dataSource
+	// sourceName identifies the source in metric labels, without
+	// credentials.
+	sourceName() string
+}
+
+// newDataSource returns the source named by location: an ftp:// or smb://
+// URL, a SQLite database file or, otherwise, a local directory. Files limits does not accept are never
+// returned by Newest.
+func newDataSource(location string, cfg *Config, limits *fileLimits) (dataSource, error) {
+	if strings.HasPrefix(location, "ftp://") {
+		return newFTPSource(location, time.Duration(cfg.IOTimeout)*time.Second, limits)
+	}
+	if strings.HasPrefix(location, "smb://") {
+		return newSMBSource(location, cfg.SMBCredentials, time.Duration(cfg.IOTimeout)*time.Second, limits)
+	}
+
+	if isSQLiteLocation(location) {
+		return newSQLiteSource(location, cfg.SQLiteQuery, time.Duration(cfg.IOTimeout)*time.Second)
+	}
+
+	src := &localSource{dataDir: location, limits: limits, scanWorkers: cfg.ScanConcurrency}
+	if cfg.FileIndex != "" {
+		src.walker = newIndexedWalker(cfg.FileIndex)
+	}
+	if cfg.Watch {
+		watch, err := newDirWatcher(location)
+		if err != nil {
+			return nil, fmt.Errorf("watching %s: %w", location, err)
+		}
+		src.watch = watch
+	}
+	return src, nil
+}
+
+// localSource reads from a directory tree on a local (or mounted) filesystem.
+type localSource struct {
+	dataDir string
+	walker  *indexedWalker
+	limits  *fileLimits
+	// scanWorkers is the number of directories listed at once, if more
+	// than one.
+	scanWorkers int
+	// ranked is the accepted CSV files of the last walk, newest first.
+	ranked []fileStat
+	// watch, with --watch, tells which files were written since the last
+	// Newest, which was last.
+	watch *dirWatcher
+	last  fileStat
+}
+
+func (s *localSource) Newest() (fileStat, error) {
+	if s.watch != nil {
+		if newest, ok := s.watchedNewest(); ok {
+			return newest, nil
+		}
+	}
+	newest, err := s.walkNewest()
+	if err == nil {
+		s.last = newest
+	}
+	return newest, err
+}
+
+// walkNewest walks the data directory for the newest file.
+func (s *localSource) walkNewest() (fileStat, error) {
+	var err error
+	switch {
+	case s.walker != nil:
+		s.ranked, err = s.walker.rank(s.dataDir, s.limits)
+	case s.scanWorkers > 1:
+		s.ranked, err = scanCSVFiles(s.dataDir, s.limits, s.scanWorkers)
+	default:
+		s.ranked, err = rankCSVFiles(s.dataDir, s.limits)
+	}
+	if err != nil {
+		return fileStat{}, err
+	}
+	if len(s.ranked) == 0 {
+		return fileStat{}, fmt.Errorf("no CSV files in %s", s.dataDir)
+	}
+
+	newest, err := s.stat(s.ranked[0].Path)
+	if os.IsNotExist(err) {
+		if next, nextErr := s.nextNewest(s.ranked[0].Path); nextErr == nil {
+			return next, nil
+		}
+	}
+	return newest, err
+}
+
+// nextNewest returns the newest file of the last walk that is older than
+// path and still exists, for when path vanished before it could be read.
+func (s *localSource) nextNewest(path string) (fileStat, error) {
+	i := 0
+	for i < len(s.ranked) && s.ranked[i].Path != path {
+		i++
+	}
+	for _, candidate := range s.ranked[min(i+1, len(s.ranked)):] {
+		next, err := s.stat(candidate.Path)
+		if err == nil {
+			noteVanished(path, next.Path)
+			return next, nil
+		}
+		if !os.IsNotExist(err) {
+			break
+		}
+	}
+	noteVanished(path, "")
+	return fileStat{}, errNoOlderFile
+}
+
+func (s *localSource) stat(path string) (fileStat, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{Path: path, ModTime: info.ModTime(), Size: info.Size()}, nil
+}
+
+func (s *localSource) Open(path string, offset int64) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// deadlineConn is a net.Conn that pushes its deadline out by timeout before
+// every read and write, turning a silently dead peer into an error.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Write(b)
+}
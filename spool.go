@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	SPOOL_PROCESSED_DIR    = "processed"
+	SPOOL_SETTLE_TIME      = 5 * time.Second
+	SPOOL_ACTION_MOVE      = "move"
+	SPOOL_ACTION_DELETE    = "delete"
+	SPOOL_PROGRESS_FILE    = ".spool-progress.json"
+	SPOOL_PROGRESS_VERSION = 1
+)
+
+// spoolDatePattern matches a date, optionally followed by a time, embedded in
+// a file name such as daqs_2023-06-01.csv or daqs.20230601_1300.csv.
+var spoolDatePattern = regexp.MustCompile(`(\d{4})[-_]?(\d{2})[-_]?(\d{2})(?:[-_T ]?(\d{2})[-_:]?(\d{2})(?:[-_:]?(\d{2}))?)?`)
+
+type spoolFile struct {
+	path    string
+	order   time.Time
+	modTime time.Time
+	size    int64
+}
+
+// listSpool returns the CSV files directly in dir ordered by the date
+// embedded in their name, falling back to mtime when there is none. Files
+// modified within SPOOL_SETTLE_TIME may still be being written and are left
+// for the next cycle.
+func listSpool(dir string) ([]spoolFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []spoolFile
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".csv" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) < SPOOL_SETTLE_TIME {
+			continue
+		}
+
+		order := info.ModTime()
+		if t, ok := embeddedDate(e.Name()); ok {
+			order = t
+		}
+		files = append(files, spoolFile{path: filepath.Join(dir, e.Name()), order: order, modTime: info.ModTime(), size: info.Size()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].order.Equal(files[j].order) {
+			return files[i].order.Before(files[j].order)
+		}
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
+
+func embeddedDate(name string) (time.Time, bool) {
+	m := spoolDatePattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	for i := 4; i <= 6; i++ {
+		if m[i] == "" {
+			m[i] = "00"
+		}
+	}
+	t, err := time.ParseInLocation("20060102150405", m[1]+m[2]+m[3]+m[4]+m[5]+m[6], time.Local)
+	return t, err == nil
+}
+
+// finishSpoolFile removes a fully processed file from the spool, either by
+// moving it to the processed/ subdirectory or deleting it.
+func finishSpoolFile(path string, action string) error {
+	if action == SPOOL_ACTION_DELETE {
+		return os.Remove(path)
+	}
+
+	dir := filepath.Join(filepath.Dir(path), SPOOL_PROCESSED_DIR)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, filepath.Join(dir, filepath.Base(path)))
+}
+
+// spoolProgress is an on-disk record of how far the rows of each spool file
+// have been processed, so that after a restart, or a failure to move or
+// delete a finished file, its rows are not processed again.
+type spoolProgress struct {
+	Version int                      `json:"version"`
+	Files   map[string]spoolPosition `json:"files"`
+}
+
+// spoolPosition is how far a spool file was processed, as it was when it
+// had ModTime: the tail offset and row count after its last processed row,
+// and whether all of it was.
+type spoolPosition struct {
+	ModTime time.Time `json:"mtime"`
+	Offset  int64     `json:"offset"`
+	Rows    int       `json:"rows"`
+	Done    bool      `json:"done,omitempty"`
+}
+
+// loadSpoolProgress reads the progress saved in dir. As with the file index,
+// any problem with it just means starting without one.
+func loadSpoolProgress(dir string) *spoolProgress {
+	progress := &spoolProgress{Version: SPOOL_PROGRESS_VERSION, Files: make(map[string]spoolPosition)}
+	path := filepath.Join(dir, SPOOL_PROGRESS_FILE)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Ignoring spool progress %s: %v", path, err)
+		}
+		return progress
+	}
+
+	var saved spoolProgress
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Version != SPOOL_PROGRESS_VERSION || saved.Files == nil {
+		log.Printf("Ignoring unusable spool progress %s", path)
+		return progress
+	}
+	return &saved
+}
+
+// position returns how far f was processed; nothing of it if the file was
+// replaced since.
+func (p *spoolProgress) position(f spoolFile) spoolPosition {
+	pos, ok := p.Files[filepath.Base(f.path)]
+	if !ok || !pos.ModTime.Equal(f.modTime) {
+		return spoolPosition{}
+	}
+	return pos
+}
+
+// set records pos for f and saves the progress to dir.
+func (p *spoolProgress) set(dir string, f spoolFile, pos spoolPosition) error {
+	pos.ModTime = f.modTime
+	p.Files[filepath.Base(f.path)] = pos
+	return p.save(dir)
+}
+
+// forget drops f, once finished, and saves the progress to dir.
+func (p *spoolProgress) forget(dir string, f spoolFile) error {
+	delete(p.Files, filepath.Base(f.path))
+	return p.save(dir)
+}
+
+// save writes the progress atomically so a crash never leaves a torn file.
+func (p *spoolProgress) save(dir string) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, SPOOL_PROGRESS_FILE)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
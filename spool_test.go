@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpoolProgressSurvivesReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "daqs_2024-06-01.csv")
+	if err := os.WriteFile(path, []byte("DataTime,Unix Time,Status\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	files, err := listSpool(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("listSpool got %v, %v; want the one file", files, err)
+	}
+	f := files[0]
+
+	if err := loadSpoolProgress(dir).set(dir, f, spoolPosition{Offset: 120, Rows: 3}); err != nil {
+		t.Fatal(err)
+	}
+	pos := loadSpoolProgress(dir).position(f)
+	if pos.Offset != 120 || pos.Rows != 3 || pos.Done {
+		t.Errorf("got %+v after reload, want offset 120, 3 rows, not done", pos)
+	}
+
+	replaced := f
+	replaced.modTime = f.modTime.Add(time.Second)
+	if pos := loadSpoolProgress(dir).position(replaced); pos != (spoolPosition{}) {
+		t.Errorf("got %+v for a replaced file, want nothing processed", pos)
+	}
+
+	progress := loadSpoolProgress(dir)
+	if err := progress.forget(dir, f); err != nil {
+		t.Fatal(err)
+	}
+	if pos := loadSpoolProgress(dir).position(f); pos != (spoolPosition{}) {
+		t.Errorf("got %+v after forget, want nothing", pos)
+	}
+}
+
+func TestListSpoolSkipsProgressFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := (&spoolProgress{Version: SPOOL_PROGRESS_VERSION, Files: map[string]spoolPosition{}}).save(dir); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(filepath.Join(dir, SPOOL_PROGRESS_FILE), old, old)
+	files, err := listSpool(dir)
+	if err != nil || len(files) != 0 {
+		t.Errorf("listSpool got %v, %v; want no files", files, err)
+	}
+}
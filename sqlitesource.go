@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DEFAULT_SQLITE_QUERY returns the latest row of every module from a table
+// readings(timestamp, module, vin, pin, temp, rssi).
+const DEFAULT_SQLITE_QUERY = `SELECT r.timestamp, r.module, r.vin, r.pin, r.temp, r.rssi
+FROM readings r
+JOIN (SELECT module, MAX(timestamp) AS timestamp FROM readings GROUP BY module) latest
+  ON r.module = latest.module AND r.timestamp = latest.timestamp
+ORDER BY r.module`
+
+// daqsColumnNames are the header suffixes of a module's columns in a DAQS
+// file, in order.
+var daqsColumnNames = [MODULE_COLUMNS]string{
+	"Vin", "Iin", "Temp", "Pwm", "Status", "Flags", "RSSI", "BRSSI", "ID", "Vout", "Details", "Pin",
+}
+
+// sqliteFields maps the result columns of the query to module column
+// offsets. "timestamp" and "module" are required, the others optional.
+var sqliteFields = map[string]int{
+	"vin":  VIN_OFFSET,
+	"pin":  PIN_OFFSET,
+	"temp": TEMP_OFFSET,
+	"rssi": RSSI_OFFSET,
+}
+
+// sqliteSource reads a SQLite database written by gateway firmware that no
+// longer logs CSV. Every Newest runs the query and renders its rows, one per
+// module, as a DAQS file of a single record, which Open then serves; so the
+// rest of the exporter handles it like any CSV file. The file is modified
+// when the newest timestamp in the result changes. It must be read in full
+// every time, as with --full-reread.
+type sqliteSource struct {
+	path    string
+	query   string
+	timeout time.Duration
+	db      *sql.DB
+
+	rendered []byte
+}
+
+func newSQLiteSource(path, query string, timeout time.Duration) (*sqliteSource, error) {
+	if query == "" {
+		query = DEFAULT_SQLITE_QUERY
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	return &sqliteSource{path: path, query: query, timeout: timeout, db: db}, nil
+}
+
+// isSQLiteLocation reports whether location names a SQLite database.
+func isSQLiteLocation(location string) bool {
+	return strings.HasSuffix(location, ".db") || strings.HasSuffix(location, ".sqlite")
+}
+
+func (s *sqliteSource) Newest() (fileStat, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.query)
+	if err != nil {
+		return fileStat{}, fmt.Errorf("querying %s: %w", s.path, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fileStat{}, err
+	}
+	tsColumn, moduleColumn := -1, -1
+	for i, c := range columns {
+		switch strings.ToLower(c) {
+		case "timestamp":
+			tsColumn = i
+		case "module":
+			moduleColumn = i
+		}
+	}
+	if tsColumn < 0 || moduleColumn < 0 {
+		return fileStat{}, fmt.Errorf("query of %s must return timestamp and module columns", s.path)
+	}
+
+	headers := []string{"DataTime", "Unix Time", "Status"}
+	record := make([]string, LEADING_COLUMNS)
+	newest := 0.0
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return fileStat{}, fmt.Errorf("reading rows of %s: %w", s.path, err)
+		}
+		ts, err := sqliteTimestamp(*values[tsColumn].(*interface{}))
+		if err != nil {
+			return fileStat{}, fmt.Errorf("rows of %s: %w", s.path, err)
+		}
+		if ts > newest {
+			newest = ts
+		}
+
+		module := sqliteText(*values[moduleColumn].(*interface{}))
+		block := make([]string, MODULE_COLUMNS)
+		for i, c := range columns {
+			if offset, ok := sqliteFields[strings.ToLower(c)]; ok {
+				block[offset] = sqliteText(*values[i].(*interface{}))
+			}
+		}
+		for _, name := range daqsColumnNames {
+			headers = append(headers, module+"_"+name)
+		}
+		record = append(record, block...)
+	}
+	if err := rows.Err(); err != nil {
+		return fileStat{}, fmt.Errorf("reading rows of %s: %w", s.path, err)
+	}
+	if newest == 0 {
+		return fileStat{}, fmt.Errorf("no rows in %s", s.path)
+	}
+
+	modTime := time.Unix(0, int64(newest*float64(time.Second)))
+	record[0] = modTime.UTC().Format("2006/01/02 15:04:05.000")
+	record[TIMESTAMP_COLUMN] = strconv.FormatFloat(newest, 'f', -1, 64)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(headers)
+	w.Write(record)
+	w.Flush()
+	s.rendered = buf.Bytes()
+
+	return fileStat{Path: s.path, ModTime: modTime, Size: int64(len(s.rendered))}, nil
+}
+
+func (s *sqliteSource) Open(path string, offset int64) (io.ReadCloser, error) {
+	if path != s.path || s.rendered == nil {
+		return nil, fmt.Errorf("%s has not been queried", path)
+	}
+	if offset > int64(len(s.rendered)) {
+		offset = int64(len(s.rendered))
+	}
+	return io.NopCloser(bytes.NewReader(s.rendered[offset:])), nil
+}
+
+// sqliteTimestamp converts a timestamp column value, unix seconds or a
+// date and time, to unix seconds.
+func sqliteTimestamp(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case time.Time:
+		return float64(v.UnixNano()) / float64(time.Second), nil
+	case string, []byte:
+		text := sqliteText(v)
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f, nil
+		}
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02 15:04:05.000"} {
+			if t, err := time.Parse(layout, text); err == nil {
+				return float64(t.UnixNano()) / float64(time.Second), nil
+			}
+		}
+		return 0, fmt.Errorf("invalid timestamp %q", text)
+	}
+	return 0, fmt.Errorf("invalid timestamp %v", v)
+}
+
+// sqliteText renders a column value as a CSV field; NULL becomes empty.
+func sqliteText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
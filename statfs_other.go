@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// diskFree is not implemented off Linux.
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("statfs not supported on this platform")
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	stringRecoveredWatts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_string_recovered_watts",
+			Help: help("tigo_string_recovered_watts", "Estimated power recovered by optimizers in W: summed module power minus module count times the weakest module's power"),
+		},
+		[]string{"string"},
+	)
+	stringPowerImbalance = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_string_power_imbalance",
+			Help: help("tigo_string_power_imbalance", "Difference between the highest and lowest module power within a string in W"),
+		},
+		[]string{"string"},
+	)
+	stringRecoveredEnergy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_string_recovered_energy_today_wh",
+			Help: help("tigo_string_recovered_energy_today_wh", "Estimated energy recovered by optimizers since local midnight in Wh"),
+		},
+		[]string{"string"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(stringRecoveredWatts)
+	prometheus.MustRegister(stringRecoveredEnergy)
+	prometheus.MustRegister(stringPowerImbalance)
+}
+
+// stringGroup is a named set of modules wired in series.
+type stringGroup struct {
+	Name    string
+	Modules []string
+}
+
+// parseStringGroups parses --string definitions of the form NAME=A1,A2,A3.
+func parseStringGroups(specs []string) ([]stringGroup, error) {
+	var groups []stringGroup
+	seen := make(map[string]bool)
+
+	for _, spec := range specs {
+		name, members, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.TrimSpace(members) == "" {
+			return nil, fmt.Errorf("invalid string definition %q: want NAME=MODULE,MODULE,...", spec)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("string %q defined more than once", name)
+		}
+		seen[name] = true
+
+		group := stringGroup{Name: name}
+		for _, module := range strings.Split(members, ",") {
+			module = strings.TrimSpace(module)
+			if module == "" {
+				return nil, fmt.Errorf("invalid string definition %q: empty module name", spec)
+			}
+			group.Modules = append(group.Modules, module)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// stringState is the per-string integration state carried between rows.
+type stringState struct {
+	lastTimestamp float64
+	lastRecovered float64
+	haveLast      bool
+	day           string
+	energyToday   float64
+}
+
+// stringTracker computes per-string derived metrics from every parsed row.
+// Daily energy starts over at midnight in location.
+type stringTracker struct {
+	groups   []stringGroup
+	location *time.Location
+	state    map[string]*stringState
+}
+
+func newStringTracker(groups []stringGroup, location *time.Location) *stringTracker {
+	t := &stringTracker{groups: groups, location: location, state: make(map[string]*stringState)}
+	for _, g := range groups {
+		t.state[g.Name] = &stringState{}
+	}
+	return t
+}
+
+// moduleIndexes maps module names to their 1-based index in the current file.
+func moduleIndexes(moduleCount int) map[string]int {
+	indexes := make(map[string]int, moduleCount)
+	for i := 1; i <= moduleCount; i++ {
+		indexes[moduleName(i)] = i
+	}
+	return indexes
+}
+
+// observe feeds one record through the string calculations. Rows that are
+// not newer than the last one seen for a string are ignored so a file read
+// again from the top does not count energy twice.
+func (t *stringTracker) observe(record []string, moduleCount int) {
+	if len(t.groups) == 0 {
+		return
+	}
+
+	ts, err := recordTimestamp(record)
+	if err != nil {
+		return
+	}
+	indexes := moduleIndexes(moduleCount)
+	day := time.Unix(int64(ts), 0).In(t.location).Format("2006-01-02")
+
+	for _, g := range t.groups {
+		st := t.state[g.Name]
+		if st.haveLast && ts <= st.lastTimestamp {
+			continue
+		}
+
+		if imbalance, ok := powerImbalance(record, g, indexes); ok {
+			stringPowerImbalance.WithLabelValues(g.Name).Set(imbalance)
+		} else {
+			stringPowerImbalance.DeleteLabelValues(g.Name)
+		}
+
+		recovered, ok := recoveredPower(record, g, indexes)
+		if !ok {
+			// A member is missing: the estimate would be meaningless, and
+			// the interval it covers must not be integrated either.
+			stringRecoveredWatts.DeleteLabelValues(g.Name)
+			st.haveLast = false
+			continue
+		}
+
+		if st.day != day {
+			st.day = day
+			st.energyToday = 0
+		}
+		if st.haveLast {
+			dt := ts - st.lastTimestamp
+			if dt <= STALE_TIMEOUT.Seconds() {
+				st.energyToday += st.lastRecovered * dt / 3600
+			}
+		}
+		st.lastTimestamp = ts
+		st.lastRecovered = recovered
+		st.haveLast = true
+
+		stringRecoveredWatts.WithLabelValues(g.Name).Set(recovered)
+		stringRecoveredEnergy.WithLabelValues(g.Name).Set(st.energyToday)
+	}
+}
+
+// recoveredPower returns how much more power the string produced than it
+// would have if every module were limited to the weakest one's output.
+func recoveredPower(record []string, g stringGroup, indexes map[string]int) (float64, bool) {
+	sum := 0.0
+	weakest := math.Inf(1)
+	for _, module := range g.Modules {
+		index, ok := indexes[module]
+		if !ok {
+			return 0, false
+		}
+		power, err := moduleField(record, index, PIN_OFFSET)
+		if err != nil {
+			return 0, false
+		}
+		sum += power
+		weakest = math.Min(weakest, power)
+	}
+	return sum - float64(len(g.Modules))*weakest, true
+}
+
+// powerImbalance returns the spread between the strongest and weakest
+// reporting module of the string, if at least two of them report.
+func powerImbalance(record []string, g stringGroup, indexes map[string]int) (float64, bool) {
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	reporting := 0
+	for _, module := range g.Modules {
+		index, ok := indexes[module]
+		if !ok {
+			continue
+		}
+		power, err := moduleField(record, index, PIN_OFFSET)
+		if err != nil {
+			continue
+		}
+		lowest = math.Min(lowest, power)
+		highest = math.Max(highest, power)
+		reporting++
+	}
+	if reporting < 2 {
+		return 0, false
+	}
+	return highest - lowest, true
+}
+
+// reset drops the instantaneous string series when the data goes stale. The
+// daily energy totals are kept.
+func (t *stringTracker) reset() {
+	stringRecoveredWatts.Reset()
+	stringPowerImbalance.Reset()
+	for _, st := range t.state {
+		st.haveLast = false
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStringTrackerDayInLocation(t *testing.T) {
+	pacific := time.FixedZone("PDT", -7*3600)
+	tracker := newStringTracker([]stringGroup{{Name: "S1", Modules: []string{"A1", "A2"}}}, pacific)
+
+	// 03:00 UTC is still the evening before at the site.
+	ts := time.Date(2024, 6, 2, 3, 0, 0, 0, time.UTC).Unix()
+	tracker.observe(testRecord(ts, 2, 100), 2)
+	if day := tracker.state["S1"].day; day != "2024-06-01" {
+		t.Errorf("got day %s, want 2024-06-01 in --timezone", day)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// moduleStuck is only registered with --stuck-after.
+var moduleStuck = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tigo_module_stuck",
+		Help: help("tigo_module_stuck", "Whether the power field of the module has been identical for --stuck-after rows while other modules changed"),
+	},
+	[]string{"name"},
+)
+
+// stuckDetector flags modules whose sensor has frozen: their power field
+// holds exactly the same text row after row while the data timestamp
+// advances. Only rows in which the power of some other module changed count
+// towards the streak, so an array at rest, such as every module at 0 at
+// night, is not taken for one full of stuck sensors. A module is flagged
+// once its streak reaches after and cleared as soon as its value changes.
+type stuckDetector struct {
+	after         int
+	last          map[string]string
+	streak        map[string]int
+	lastTimestamp float64
+	haveLast      bool
+}
+
+func newStuckDetector(after int) *stuckDetector {
+	return &stuckDetector{
+		after:  after,
+		last:   make(map[string]string),
+		streak: make(map[string]int),
+	}
+}
+
+func (d *stuckDetector) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil || (d.haveLast && ts <= d.lastTimestamp) {
+		return
+	}
+	d.lastTimestamp = ts
+	d.haveLast = true
+
+	values := make(map[string]string, moduleCount)
+	var changed []string
+	for i := 1; i <= moduleCount; i++ {
+		column := LEADING_COLUMNS + (i-1)*MODULE_COLUMNS + PIN_OFFSET
+		if column >= len(record) || record[column] == "" {
+			continue
+		}
+		name := moduleName(i)
+		values[name] = record[column]
+		if previous, ok := d.last[name]; ok && previous != record[column] {
+			changed = append(changed, name)
+		}
+	}
+
+	for _, name := range changed {
+		d.streak[name] = 0
+		moduleStuck.WithLabelValues(name).Set(0)
+	}
+	if len(changed) > 0 {
+		for name, value := range values {
+			if previous, ok := d.last[name]; !ok || previous != value {
+				continue
+			}
+			d.streak[name]++
+			if d.streak[name] >= d.after {
+				moduleStuck.WithLabelValues(name).Set(1)
+			} else {
+				moduleStuck.WithLabelValues(name).Set(0)
+			}
+		}
+	}
+	for name, value := range values {
+		d.last[name] = value
+	}
+}
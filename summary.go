@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snapshot is the reader's view of the most recently published record. It is
+// guarded by the same mutex as the gauge updates.
+type snapshot struct {
+	File         string
+	FileModified time.Time
+	ModuleCount  int
+	Timestamp    float64
+	Modules      []moduleSnapshot
+}
+
+// moduleSnapshot holds the values published for one module, keyed by metric
+// suffix ("power", "volts", ...). Fields that failed to parse are absent.
+type moduleSnapshot struct {
+	Name   string
+	Values map[string]float64
+	// Alias is the name the CCA logs the module under.
+	Alias string
+	// LastSeen is the data timestamp of the last record the module
+	// reported any value in, 0 if none since startup.
+	LastSeen float64
+}
+
+// systemPower sums the power of every module that reported one.
+func (s *snapshot) systemPower() float64 {
+	total := 0.0
+	for _, m := range s.Modules {
+		total += m.Values["power"]
+	}
+	return total
+}
+
+// logSummary writes a one-off human readable dump of s to the log.
+func logSummary(s *snapshot) {
+	if s.File == "" {
+		log.Printf("Summary: no data read yet")
+		return
+	}
+
+	log.Printf("Summary: file %s (modified %s, %s ago)", s.File,
+		s.FileModified.Format(time.RFC3339), time.Since(s.FileModified).Round(time.Second))
+	dataTime := time.Unix(int64(s.Timestamp), 0)
+	log.Printf("Summary: data timestamp %s (%s ago)", dataTime.Format(time.RFC3339),
+		time.Since(dataTime).Round(time.Second))
+	log.Printf("Summary: %d modules, system power %.1f W", s.ModuleCount, s.systemPower())
+
+	for _, m := range s.Modules {
+		keys := make([]string, 0, len(m.Values))
+		for k := range m.Values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fields := make([]string, 0, len(keys))
+		for _, k := range keys {
+			fields = append(fields, k+"="+strconv.FormatFloat(m.Values[k], 'f', -1, 64))
+		}
+		log.Printf("Summary: %s %s", m.Name, strings.Join(fields, " "))
+	}
+}
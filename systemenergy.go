@@ -0,0 +1,75 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var systemEnergyToday = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "tigo_system_energy_today_wh",
+		Help: help("tigo_system_energy_today_wh", "Energy produced by the whole array since local midnight in Wh"),
+	},
+)
+
+func init() {
+	prometheus.MustRegister(systemEnergyToday)
+}
+
+// systemEnergy integrates the array's total power over the rows of the day.
+// Because the first read after a start covers the whole current file, a
+// restart recomputes the day's total from the data instead of losing it.
+type systemEnergy struct {
+	location      *time.Location
+	day           string
+	lastTimestamp float64
+	lastPower     float64
+	haveLast      bool
+	energyToday   float64
+}
+
+func newSystemEnergy(location *time.Location) *systemEnergy {
+	return &systemEnergy{location: location}
+}
+
+// observe adds the energy of the interval ending at record. Rows not newer
+// than the previous one are ignored and intervals longer than STALE_TIMEOUT
+// are not integrated.
+func (e *systemEnergy) observe(record []string, moduleCount int) {
+	ts, err := recordTimestamp(record)
+	if err != nil || (e.haveLast && ts <= e.lastTimestamp) {
+		return
+	}
+
+	power := 0.0
+	reporting := 0
+	for i := 1; i <= moduleCount; i++ {
+		pin, err := moduleField(record, i, PIN_OFFSET)
+		if err != nil {
+			continue
+		}
+		power += pin
+		reporting++
+	}
+	if reporting == 0 {
+		return
+	}
+
+	day := time.Unix(int64(ts), 0).In(e.location).Format("2006-01-02")
+	if day != e.day {
+		e.day = day
+		e.energyToday = 0
+		e.haveLast = false
+	}
+	if e.haveLast {
+		if dt := ts - e.lastTimestamp; dt <= STALE_TIMEOUT.Seconds() {
+			e.energyToday += e.lastPower * dt / 3600
+		}
+	}
+	e.lastTimestamp = ts
+	e.lastPower = power
+	e.haveLast = true
+
+	systemEnergyToday.Set(e.energyToday)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+)
+
+// csvTail remembers how far into the current CSV file we have read, so that
+// when the same file has merely grown only the appended bytes are parsed.
+type csvTail struct {
+	path    string
+	offset  int64
+	headers []string
+	width   int
+	modules int
+	layout  *channelLayout
+	opts    csvOptions
+	// rows is the number of records read from the file so far.
+	rows int
+	// maxLine, if set, makes read fail with errLineTooLong on any line
+	// longer than this many bytes.
+	maxLine int
+}
+
+// readAll reads file to the end within an "open" span of ctx.
+func readAll(ctx context.Context, src dataSource, path string, offset int64) ([]byte, error) {
+	_, span := startSpan(ctx, "open", attrFile.String(path))
+	defer span.End()
+
+	file, err := src.Open(path, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	span.SetAttributes(attrBytesRead.Int(len(data)))
+	return data, err
+}
+
+// read returns the records of path that have not been returned before. A new
+// path, a file that shrank (truncation) or fullReread all cause the file to
+// be read again from the top. Only newline-terminated records are consumed in
+// incremental mode; a partially written last line is picked up next time.
+//
+// Reading and parsing are traced as "open" and "parse" spans of ctx.
+func (t *csvTail) read(ctx context.Context, src dataSource, path string, size int64, fullReread bool) (*daqsFile, error) {
+	if fullReread {
+		t.path, t.offset, t.headers = "", 0, nil
+		data, err := readAll(ctx, src, path, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkLineLength(data, t.maxLine); err != nil {
+			return nil, err
+		}
+		_, span := startSpan(ctx, "parse", attrFile.String(path))
+		defer span.End()
+		daqs, err := parseDAQS(bytes.NewReader(data), path, t.opts)
+		if err == nil {
+			span.SetAttributes(attrRows.Int(len(daqs.Records)), attrModules.Int(daqs.ModuleCount))
+		}
+		return daqs, err
+	}
+
+	if path != t.path || size < t.offset || t.headers == nil {
+		t.path, t.offset, t.headers = path, 0, nil
+	}
+
+	data, err := readAll(ctx, src, path, t.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkLineLength(data, t.maxLine); err != nil {
+		return nil, err
+	}
+	complete := data[:bytes.LastIndexByte(data, '\n')+1]
+
+	_, span := startSpan(ctx, "parse", attrFile.String(path))
+	defer span.End()
+
+	if t.headers == nil {
+		daqs, err := parseDAQS(bytes.NewReader(complete), path, t.opts)
+		if err != nil {
+			return nil, err
+		}
+		t.headers = daqs.Headers
+		t.width = daqs.Width
+		t.modules = daqs.ModuleCount
+		t.layout = daqs.Layout
+		if t.layout != nil {
+			t.width = t.layout.sourceWidth
+		}
+		t.offset = int64(len(complete))
+		t.rows = len(daqs.Records)
+		if daqs.StoppedAt > 0 {
+			// Resume after the bad row, so the next read gets the
+			// rows that follow it.
+			t.offset = daqs.StoppedAt
+			t.rows++
+		}
+		span.SetAttributes(attrRows.Int(len(daqs.Records)), attrModules.Int(daqs.ModuleCount))
+		return daqs, nil
+	}
+
+	daqs := &daqsFile{
+		Headers:     t.headers,
+		ModuleCount: t.modules,
+		Width:       t.width,
+		Layout:      t.layout,
+		FirstRow:    t.rows + 1,
+	}
+	if t.layout != nil {
+		daqs.Width = len(t.layout.columns)
+	}
+	// A row that does not parse is skipped and the rows after it are read
+	// on, so that they are not lost with it.
+	skipped := false
+	for rest := complete; len(rest) > 0; {
+		rdr := t.opts.newReader(bytes.NewReader(rest))
+		rdr.FieldsPerRecord = t.width
+		records, consumed, err := readRecordsAt(rdr, path, t.rows+1)
+		if skipped && len(records) > 0 {
+			// The skipped row keeps its number, so the records are
+			// no longer consecutive rows.
+			daqs.FirstRow = 0
+		}
+		t.opts.clean(records...)
+		if t.layout != nil {
+			t.layout.applyAll(records)
+		}
+		daqs.Records = append(daqs.Records, records...)
+		t.rows += len(records)
+		if err == nil {
+			t.offset += int64(len(rest))
+			break
+		}
+		log.Printf("Skipping a CSV row of %s that does not parse: %v", path, err)
+		skipped = true
+		t.rows++
+		if consumed <= 0 || consumed > int64(len(rest)) {
+			consumed = int64(len(rest))
+		}
+		t.offset += consumed
+		rest = rest[consumed:]
+	}
+
+	span.SetAttributes(attrRows.Int(len(daqs.Records)), attrModules.Int(daqs.ModuleCount))
+	return daqs, nil
+}
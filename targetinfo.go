@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// targetInfo is only registered with --target-info. Following the
+// OpenMetrics convention it is always 1 and carries identity labels to join
+// onto the tigo_* series.
+var targetInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "target_info",
+		Help: help("target_info", "Identity of the exporter and the gateway it reads, always 1"),
+	},
+	[]string{"instance", "serial", "schema"},
+)
+
+// targetIdentity keeps target_info in line with the header row of the file
+// being read, whose fingerprint is the schema label.
+type targetIdentity struct {
+	instance string
+	serial   string
+	schema   string
+}
+
+// newTargetIdentity returns the identity of an exporter named instance,
+// or after the host when that is empty, reading the gateway with serial.
+func newTargetIdentity(instance, serial string) *targetIdentity {
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	return &targetIdentity{instance: instance, serial: serial}
+}
+
+// update publishes the identity for a file with headers.
+func (t *targetIdentity) update(headers []string) {
+	schema := schemaFingerprint(headers)
+	if schema == t.schema {
+		return
+	}
+	t.schema = schema
+	targetInfo.Reset()
+	targetInfo.WithLabelValues(t.instance, t.serial, schema).Set(1)
+}
+
+// schemaFingerprint identifies a header row, and with it the firmware that
+// wrote it, by the first 12 hex digits of its SHA-256.
+func schemaFingerprint(headers []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(headers, ",")))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,136 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: tigo.proto
+
+package main
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Exporter_Subscribe_FullMethodName = "/tigo.Exporter/Subscribe"
+)
+
+// ExporterClient is the client API for Exporter service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ExporterClient interface {
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Exporter_SubscribeClient, error)
+}
+
+type exporterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewExporterClient(cc grpc.ClientConnInterface) ExporterClient {
+	return &exporterClient{cc}
+}
+
+func (c *exporterClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Exporter_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Exporter_ServiceDesc.Streams[0], Exporter_Subscribe_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &exporterSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Exporter_SubscribeClient interface {
+	Recv() (*Snapshot, error)
+	grpc.ClientStream
+}
+
+type exporterSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *exporterSubscribeClient) Recv() (*Snapshot, error) {
+	m := new(Snapshot)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ExporterServer is the server API for Exporter service.
+// All implementations must embed UnimplementedExporterServer
+// for forward compatibility
+type ExporterServer interface {
+	Subscribe(*SubscribeRequest, Exporter_SubscribeServer) error
+	mustEmbedUnimplementedExporterServer()
+}
+
+// UnimplementedExporterServer must be embedded to have forward compatible implementations.
+type UnimplementedExporterServer struct {
+}
+
+func (UnimplementedExporterServer) Subscribe(*SubscribeRequest, Exporter_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedExporterServer) mustEmbedUnimplementedExporterServer() {}
+
+// UnsafeExporterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ExporterServer will
+// result in compilation errors.
+type UnsafeExporterServer interface {
+	mustEmbedUnimplementedExporterServer()
+}
+
+func RegisterExporterServer(s grpc.ServiceRegistrar, srv ExporterServer) {
+	s.RegisterService(&Exporter_ServiceDesc, srv)
+}
+
+func _Exporter_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ExporterServer).Subscribe(m, &exporterSubscribeServer{stream})
+}
+
+type Exporter_SubscribeServer interface {
+	Send(*Snapshot) error
+	grpc.ServerStream
+}
+
+type exporterSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *exporterSubscribeServer) Send(m *Snapshot) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Exporter_ServiceDesc is the grpc.ServiceDesc for Exporter service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Exporter_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tigo.Exporter",
+	HandlerType: (*ExporterServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Exporter_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tigo.proto",
+}
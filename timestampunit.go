@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+const (
+	TIMESTAMP_UNIT_AUTO = "auto"
+	TIMESTAMP_UNIT_S    = "s"
+	TIMESTAMP_UNIT_MS   = "ms"
+	// MS_TIMESTAMP_MIN is far beyond any epoch second for centuries to
+	// come, and already passed as an epoch millisecond in 1973.
+	MS_TIMESTAMP_MIN = 1e11
+)
+
+// timestampUnit is set by --timestamp-unit and tells recordTimestamp what
+// the timestamp column counts.
+var timestampUnit = TIMESTAMP_UNIT_AUTO
+
+var logDetectedUnit sync.Once
+
+func parseTimestampUnit(unit string) (string, error) {
+	switch unit {
+	case "":
+		return TIMESTAMP_UNIT_AUTO, nil
+	case TIMESTAMP_UNIT_AUTO, TIMESTAMP_UNIT_S, TIMESTAMP_UNIT_MS:
+		return unit, nil
+	}
+	return "", fmt.Errorf("invalid --timestamp-unit %q: must be %s, %s or %s", unit, TIMESTAMP_UNIT_AUTO, TIMESTAMP_UNIT_S, TIMESTAMP_UNIT_MS)
+}
+
+// toSeconds returns ts in epoch seconds. With auto, timestamps of
+// MS_TIMESTAMP_MIN or more are taken for milliseconds, as some CCAs log.
+func toSeconds(ts float64) float64 {
+	switch timestampUnit {
+	case TIMESTAMP_UNIT_MS:
+		return ts / 1000
+	case TIMESTAMP_UNIT_AUTO:
+		if ts >= MS_TIMESTAMP_MIN {
+			logDetectedUnit.Do(func() {
+				log.Printf("Timestamps are in epoch milliseconds (%.0f); publishing them in seconds", ts)
+			})
+			return ts / 1000
+		}
+	}
+	return ts
+}
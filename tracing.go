@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/exporters/autoexport"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer makes the spans of refresh cycles. Until setupTracing installs a
+// provider it hands out no-op spans, which cost next to nothing.
+var tracer = otel.Tracer("github.com/zestysoft/tigo-exporter")
+
+// Span attributes.
+var (
+	attrFile      = attribute.Key("tigo.file.path")
+	attrBytesRead = attribute.Key("tigo.file.bytes_read")
+	attrRows      = attribute.Key("tigo.rows")
+	attrModules   = attribute.Key("tigo.modules")
+	attrPublished = attribute.Key("tigo.published")
+)
+
+// setupTracing exports spans over OTLP as configured by the standard
+// OTEL_EXPORTER_OTLP_* and OTEL_TRACES_EXPORTER variables, with the service
+// named by OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES or else
+// tigo-exporter. The returned function flushes what is still buffered.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	exporter, err := autoexport.NewSpanExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName("tigo-exporter")),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// traceScrapes makes a span of every request to next.
+func traceScrapes(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "scrape")
+}
+
+// startSpan starts a child span of ctx named name.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var errNoOlderFile = errors.New("no older CSV file left")
+
+var vanishedFiles = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "tigo_vanished_files_total",
+		Help: help("tigo_vanished_files_total", "Number of times the newest CSV file disappeared between being picked and being read"),
+	},
+)
+
+func init() {
+	prometheus.MustRegister(vanishedFiles)
+}
+
+// rankByAge orders candidates newest mtime first. Ties keep their walk
+// order, which is lexical, as getNewestCSVFile always did.
+func rankByAge(candidates []fileStat) []fileStat {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].ModTime.After(candidates[j].ModTime)
+	})
+	return candidates
+}
+
+// noteVanished logs and counts a picked file that was gone by the time it
+// was read, and the file used instead, if any.
+func noteVanished(path, instead string) {
+	vanishedFiles.Inc()
+	if instead == "" {
+		log.Printf("CSV file %s vanished before it could be read and no older one is left", path)
+		return
+	}
+	log.Printf("CSV file %s vanished before it could be read, using %s instead", path, instead)
+}
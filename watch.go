@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// dirWatcher follows writes to the CSV files of a data directory tree with
+// fsnotify, for --watch. Directories created later, such as the one of a new
+// day, are watched as they appear.
+type dirWatcher struct {
+	watcher *fsnotify.Watcher
+	// changed is signalled, without blocking, whenever a CSV file is
+	// created or written.
+	changed chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]bool
+	// rescan is set when events were lost and only a walk can tell what
+	// changed.
+	rescan bool
+}
+
+func newDirWatcher(dataDir string) (*dirWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	d := &dirWatcher{
+		watcher: watcher,
+		changed: make(chan struct{}, 1),
+		pending: make(map[string]bool),
+	}
+	if err := d.addTree(dataDir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	go d.run()
+	return d, nil
+}
+
+// addTree watches root and every directory below it.
+func (d *dirWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return d.watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+func (d *dirWatcher) run() {
+	for {
+		select {
+		case event, ok := <-d.watcher.Events:
+			if !ok {
+				return
+			}
+			d.handle(event)
+		case err, ok := <-d.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Error watching the data directory: %v", err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				d.mu.Lock()
+				d.rescan = true
+				d.mu.Unlock()
+				d.signal()
+			}
+		}
+	}
+}
+
+func (d *dirWatcher) handle(event fsnotify.Event) {
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+	if event.Has(fsnotify.Create) {
+		// A new directory may already have files in it by the time it is
+		// watched, so those count as written too.
+		if err := d.addTree(event.Name); err == nil {
+			filepath.WalkDir(event.Name, func(path string, entry fs.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() && filepath.Ext(path) == ".csv" {
+					d.note(path)
+				}
+				return nil
+			})
+			return
+		}
+	}
+	if filepath.Ext(event.Name) == ".csv" {
+		d.note(event.Name)
+	}
+}
+
+func (d *dirWatcher) note(path string) {
+	d.mu.Lock()
+	d.pending[path] = true
+	d.mu.Unlock()
+	d.signal()
+}
+
+func (d *dirWatcher) signal() {
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the CSV files written since it was last called and whether
+// events were lost since.
+func (d *dirWatcher) take() ([]string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	paths := make([]string, 0, len(d.pending))
+	for path := range d.pending {
+		paths = append(paths, path)
+	}
+	clear(d.pending)
+	rescan := d.rescan
+	d.rescan = false
+	return paths, rescan
+}
+
+// watchedNewest returns the newest, by mtime, of the last newest file and
+// the CSV files written since, without walking the tree. It reports false
+// when a walk is needed: before the first one, after events were lost or
+// once the last newest file is gone.
+func (s *localSource) watchedNewest() (fileStat, bool) {
+	paths, rescan := s.watch.take()
+	if rescan || s.last.Path == "" {
+		return fileStat{}, false
+	}
+	newest, err := s.stat(s.last.Path)
+	if err != nil {
+		return fileStat{}, false
+	}
+	for _, path := range paths {
+		f, err := s.stat(path)
+		if err != nil || !s.limits.accept(f.Path, f.Size) {
+			continue
+		}
+		if f.ModTime.After(newest.ModTime) {
+			newest = f
+		}
+	}
+	if newest.Path != s.last.Path {
+		// Keep the ranking of the last walk current enough for
+		// nextNewest and --yesterday.
+		ranked := []fileStat{newest}
+		for _, f := range s.ranked {
+			if f.Path != newest.Path {
+				ranked = append(ranked, f)
+			}
+		}
+		s.ranked = ranked
+	}
+	s.last = newest
+	return newest, true
+}
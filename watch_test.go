@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestWatcher(t *testing.T, dir string) *dirWatcher {
+	t.Helper()
+	d, err := newDirWatcher(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.watcher.Close() })
+	return d
+}
+
+// waitPending waits until d has noted n files.
+func waitPending(t *testing.T, d *dirWatcher, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		d.mu.Lock()
+		got := len(d.pending)
+		d.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("fewer than %d files noted", n)
+}
+
+func TestWatchNewDirectoryWithFiles(t *testing.T) {
+	dataDir := t.TempDir()
+	d := newTestWatcher(t, dataDir)
+
+	// A dated directory moved in whole, its file written before it
+	// could be watched.
+	staging := t.TempDir()
+	day := filepath.Join(staging, "2024-06-02")
+	if err := os.Mkdir(day, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(day, "daqs.csv"), []byte("DataTime\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(day, filepath.Join(dataDir, "2024-06-02")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-d.changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change signalled for the new directory")
+	}
+	waitPending(t, d, 1)
+	paths, rescan := d.take()
+	want := filepath.Join(dataDir, "2024-06-02", "daqs.csv")
+	if rescan || len(paths) != 1 || paths[0] != want {
+		t.Fatalf("got %v, rescan %v; want [%s]", paths, rescan, want)
+	}
+
+	// And the new directory is watched from then on.
+	later := filepath.Join(dataDir, "2024-06-02", "daqs_2.csv")
+	if err := os.WriteFile(later, []byte("DataTime\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitPending(t, d, 1)
+	if paths, _ := d.take(); len(paths) != 1 || paths[0] != later {
+		t.Errorf("got %v, want [%s]", paths, later)
+	}
+}
+
+func TestWatchNewestByModTimeInBatch(t *testing.T) {
+	dataDir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	write := func(name string, mtime time.Time) string {
+		t.Helper()
+		path := filepath.Join(dataDir, name)
+		if err := os.WriteFile(path, []byte("DataTime\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("a.csv", base)
+
+	s := &localSource{dataDir: dataDir, watch: newTestWatcher(t, dataDir)}
+	if _, err := s.Newest(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Several files change in one batch; the one written last is not
+	// the newest.
+	newest := write("b.csv", base.Add(3*time.Minute))
+	write("c.csv", base.Add(2*time.Minute))
+	write("d.csv", base.Add(time.Minute))
+	waitPending(t, s.watch, 3)
+
+	got, err := s.Newest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != newest {
+		t.Errorf("got %s, want %s, the newest by mtime", got.Path, newest)
+	}
+}
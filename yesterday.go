@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DEFAULT_YESTERDAY_TOLERANCE_SEC = 300
+	SECONDS_PER_DAY                 = 24 * 60 * 60
+)
+
+// The yesterday gauges are only registered with --yesterday. The array
+// gauge is a vector without labels so that it can be absent too.
+var (
+	modulePowerYesterday = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_module_power_yesterday",
+			Help: help("tigo_module_power_yesterday", "Module power in W a day before the published record, from the closest row of the previous day"),
+		},
+		[]string{"name"},
+	)
+	arrayPowerYesterday = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tigo_array_power_yesterday",
+			Help: help("tigo_array_power_yesterday", "Total power in W of the modules a day before the published record"),
+		},
+		nil,
+	)
+)
+
+// listedSource is a dataSource that keeps the accepted files of its last
+// walk, newest first.
+type listedSource interface {
+	dataSource
+	rankedFiles() []fileStat
+}
+
+func (s *localSource) rankedFiles() []fileStat {
+	return s.ranked
+}
+
+// dayIndex is a parsed file of the previous day with its rows ordered by
+// timestamp.
+type dayIndex struct {
+	file  fileStat
+	daqs  *daqsFile
+	times []float64
+	rows  []int
+}
+
+// yesterdayLookup finds the row of a day earlier. The file holding it is
+// parsed once and kept until the time looked up moves past its last row, as
+// it does when yesterday's file was the one still being written to.
+type yesterdayLookup struct {
+	source    listedSource
+	opts      csvOptions
+	tolerance float64
+	cached    *dayIndex
+	warned    bool
+}
+
+// fileFor returns the file a row recorded at target is in: the oldest file
+// modified at or after it.
+func (y *yesterdayLookup) fileFor(target float64) (fileStat, bool) {
+	var found fileStat
+	for _, f := range y.source.rankedFiles() {
+		if float64(f.ModTime.Unix()) < target-y.tolerance {
+			break
+		}
+		found = f
+	}
+	return found, found.Path != ""
+}
+
+// needs tells whether the cached index cannot answer for target.
+func (y *yesterdayLookup) needs(file fileStat, target float64) bool {
+	c := y.cached
+	if c == nil || c.file.Path != file.Path {
+		return true
+	}
+	grown := c.file.Size != file.Size
+	return grown && (len(c.times) == 0 || c.times[len(c.times)-1] < target)
+}
+
+// load parses file and indexes its rows.
+func (y *yesterdayLookup) load(file fileStat) (*dayIndex, error) {
+	rdr, err := y.source.Open(file.Path, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Close()
+	daqs, err := parseDAQS(rdr, file.Path, y.opts)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file.Path, err)
+	}
+
+	index := &dayIndex{file: file, daqs: daqs}
+	for i, record := range daqs.Records {
+		if ts, err := recordTimestamp(record); err == nil {
+			index.times = append(index.times, ts)
+			index.rows = append(index.rows, i)
+		}
+	}
+	sort.Sort(index)
+	return index, nil
+}
+
+func (d *dayIndex) Len() int           { return len(d.times) }
+func (d *dayIndex) Less(i, j int) bool { return d.times[i] < d.times[j] }
+func (d *dayIndex) Swap(i, j int) {
+	d.times[i], d.times[j] = d.times[j], d.times[i]
+	d.rows[i], d.rows[j] = d.rows[j], d.rows[i]
+}
+
+// closest returns the record nearest to target within tolerance.
+func (d *dayIndex) closest(target, tolerance float64) ([]string, bool) {
+	i := sort.SearchFloat64s(d.times, target)
+	best, bestDist := -1, math.Inf(1)
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(d.times) {
+			continue
+		}
+		if dist := math.Abs(d.times[j] - target); dist < bestDist {
+			best, bestDist = j, dist
+		}
+	}
+	if best < 0 || bestDist > tolerance {
+		return nil, false
+	}
+	return d.daqs.Records[d.rows[best]], true
+}
+
+// find returns the record a day before ts and the file it is from. Loading
+// a file goes through guard.
+func (y *yesterdayLookup) find(ts float64, guard *ioGuard) ([]string, *daqsFile, bool) {
+	target := ts - SECONDS_PER_DAY
+	file, ok := y.fileFor(target)
+	if !ok {
+		return nil, nil, false
+	}
+	if y.needs(file, target) {
+		index, err := runIO(guard, func() (*dayIndex, error) {
+			return y.load(file)
+		})
+		if err != nil {
+			if !y.warned {
+				y.warned = true
+				log.Printf("Error reading the previous day's CSV file: %v", err)
+			}
+			return nil, nil, false
+		}
+		y.warned = false
+		y.cached = index
+	}
+	record, ok := y.cached.closest(target, y.tolerance)
+	return record, y.cached.daqs, ok
+}
+
+// publishYesterday sets the yesterday gauges from record of daqs, or drops
+// them if there is no such record.
+func publishYesterday(record []string, daqs *daqsFile, filter *moduleFilter, monitoring *monitoringOnly) {
+	modulePowerYesterday.Reset()
+	arrayPowerYesterday.Reset()
+	if record == nil {
+		return
+	}
+	total, found := 0.0, false
+	for i := 1; i <= daqs.ModuleCount; i++ {
+		name := moduleName(i)
+		if !filter.allows(name) || monitoring.is(name) {
+			continue
+		}
+		pin, err := moduleField(record, i, PIN_OFFSET)
+		if err != nil {
+			continue
+		}
+		pin = calibration.apply(name, "power", unitScale.apply("power", pin))
+		modulePowerYesterday.WithLabelValues(name).Set(pin)
+		total += pin
+		found = true
+	}
+	if found {
+		arrayPowerYesterday.WithLabelValues().Set(total)
+	}
+}